@@ -0,0 +1,84 @@
+package getql
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Cache stores the results of queries, see SelectStatsCache
+type Cache interface {
+	Get(key string) (value interface{}, ok bool)
+	// Set stores value under key for ttl, or forever if ttl is 0
+	Set(key string, value interface{}, ttl time.Duration)
+}
+
+// LRUCache is an in-memory Cache that holds at most a fixed number of
+// entries, evicting the least recently used entry first. It is safe for
+// concurrent use.
+type LRUCache struct {
+	mu       sync.Mutex
+	capacity int
+	entries  *list.List // Most recently used first
+	elements map[string]*list.Element
+}
+
+type lruEntry struct {
+	key     string
+	value   interface{}
+	expires time.Time // Zero if the entry never expires
+}
+
+func NewLRUCache(capacity int) *LRUCache {
+	return &LRUCache{
+		capacity: capacity,
+		entries:  list.New(),
+		elements: make(map[string]*list.Element),
+	}
+}
+
+func (c *LRUCache) Get(key string) (value interface{}, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.elements[key]
+	if !ok {
+		return nil, false
+	}
+	entry := element.Value.(*lruEntry)
+	if !entry.expires.IsZero() && time.Now().After(entry.expires) {
+		c.entries.Remove(element)
+		delete(c.elements, key)
+		return nil, false
+	}
+	c.entries.MoveToFront(element)
+	return entry.value, true
+}
+
+func (c *LRUCache) Set(key string, value interface{}, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var expires time.Time
+	if ttl > 0 {
+		expires = time.Now().Add(ttl)
+	}
+	if element, ok := c.elements[key]; ok {
+		entry := element.Value.(*lruEntry)
+		entry.value, entry.expires = value, expires
+		c.entries.MoveToFront(element)
+		return
+	}
+	c.elements[key] = c.entries.PushFront(&lruEntry{key: key, value: value, expires: expires})
+	for c.entries.Len() > c.capacity {
+		oldest := c.entries.Back()
+		c.entries.Remove(oldest)
+		delete(c.elements, oldest.Value.(*lruEntry).key)
+	}
+}
+
+// Len returns the number of entries in the cache, including expired entries
+// that haven't been evicted yet
+func (c *LRUCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.entries.Len()
+}
@@ -0,0 +1,79 @@
+package getql
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLRUCache(t *testing.T) {
+	cache := NewLRUCache(2)
+	cache.Set("a", 1, 0)
+	cache.Set("b", 2, 0)
+	cache.Get("a")
+	cache.Set("c", 3, 0)
+	if _, ok := cache.Get("b"); ok {
+		t.Errorf("b was not evicted")
+	}
+	if value, ok := cache.Get("a"); !ok || value != 1 {
+		t.Errorf("got a = %v, %v", value, ok)
+	}
+	cache.Set("d", 4, time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	if _, ok := cache.Get("d"); ok {
+		t.Errorf("d did not expire")
+	}
+	if cache.Len() != 1 {
+		t.Errorf("got %d entries, want 1", cache.Len())
+	}
+}
+
+func TestSelectStatsCache(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+	cache := NewLRUCache(10)
+	params := map[string][]string{
+		Sel:      []string{"id"},
+		Frm:      []string{"fruits"},
+		Col("1"): []string{"color"},
+		Opr("1"): []string{Eq},
+		Val("1"): []string{"red"},
+	}
+	options := []SelectStatsOption{SelectStatsDialect(SQLite), SelectStatsCache(cache, time.Minute, time.Minute)}
+	results, stats, err := DBSelectMapsWithStats(db, params, options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Total != 4 || len(results) != 4 {
+		t.Fatalf("got total %d and %d results, want 4 and 4", stats.Total, len(results))
+	}
+	name := results[0]["name"]
+	results[0]["name"] = "modified"
+	db.MustExec(`INSERT INTO fruits (id, name, color, price) VALUES (13, 'fruit13', 'red', 130)`)
+	results, stats, err = DBSelectMapsWithStats(db, params, options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Total != 4 || len(results) != 4 {
+		t.Errorf("got total %d and %d results, want the cached 4 and 4", stats.Total, len(results))
+	}
+	if results[0]["name"] != name {
+		t.Errorf("got cached name %v, want %v", results[0]["name"], name)
+	}
+	results[0]["name"] = "modified"
+	results, _, err = DBSelectMapsWithStats(db, params, options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[0]["name"] != name {
+		t.Errorf("got cached name %v, want %v", results[0]["name"], name)
+	}
+	// A different scope must not hit the same cache entries
+	options = append(options, SelectStatsScope(Scope{{Column: "price", Operator: Gt, Value: "0"}}))
+	_, stats, err = DBSelectMapsWithStats(db, params, options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Total != 5 {
+		t.Errorf("got total %d, want 5", stats.Total)
+	}
+}
@@ -0,0 +1,303 @@
+package getql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// CountStrategy is how SelectStats.Total is counted
+type CountStrategy int
+
+const (
+	CountExact     CountStrategy = iota // A separate SELECT COUNT(*)
+	CountWindow                         // COUNT(*) OVER() in the page query, see SelectStatsWindowCount
+	CountCapped                         // Counts no more than SelectStatsConfig.CountCap rows
+	CountEstimated                      // The planner's estimate, if the dialect is an Estimator
+	CountNone                           // No total, only SelectStats.HasNext, see SelectStatsNoCount
+)
+
+// SelectStatsCount sets the strategy for counting the total. Strategies that
+// can't be used, such as CountEstimated for a dialect that isn't an Estimator,
+// fall back to CountExact.
+func SelectStatsCount(strategy CountStrategy) SelectStatsOption {
+	return func(config SelectStatsConfig) SelectStatsConfig {
+		config.Count = strategy
+		return config
+	}
+}
+
+// SelectStatsCountCapped counts no more than max rows. If there are more, the
+// total is reported as max and SelectStats.TotalInexact is set.
+func SelectStatsCountCapped(max int) SelectStatsOption {
+	return func(config SelectStatsConfig) SelectStatsConfig {
+		config.Count = CountCapped
+		config.CountCap = max
+		return config
+	}
+}
+
+// SelectStatsWindowCount reads the total from a COUNT(*) OVER() added to the
+// page query, instead of running a separate count query. An empty page has no
+// rows to read the total from, so past the first page the total is counted as
+// usual. Only DBSelectMapsWithStats supports it, as the rows have to be read
+// before the stats can be returned.
+var SelectStatsWindowCount SelectStatsOption = SelectStatsCount(CountWindow)
+
+// SelectStatsNoCount skips counting the total. Instead the page query fetches
+// one row more than the limit to tell if there is a next page, which is
+// reported in SelectStats.HasNext. Only DBSelectMapsWithStats supports it, as
+// the extra row has to be read before the stats can be returned.
+var SelectStatsNoCount SelectStatsOption = SelectStatsCount(CountNone)
+
+var errMaterialize = errors.New("getql: CountWindow and CountNone need the rows to be read up front, use DBSelectMapsWithStats")
+
+// Estimator is implemented by dialects that can estimate the number of rows a
+// query returns without running it. Postgres is an Estimator.
+type Estimator interface {
+	EstimateRows(ctx context.Context, db Queryer, query string, args []interface{}) (int, error)
+}
+
+// EstimateRows reads the planner's row estimate from EXPLAIN
+func (d postgres) EstimateRows(ctx context.Context, db Queryer, query string, args []interface{}) (int, error) {
+	var output []byte
+	err := db.QueryRowContext(ctx, "EXPLAIN (FORMAT JSON) "+query, args...).Scan(&output)
+	if err != nil {
+		return 0, err
+	}
+	var explain []struct {
+		Plan struct {
+			Rows float64 `json:"Plan Rows"`
+		}
+	}
+	err = json.Unmarshal(output, &explain)
+	if err != nil {
+		return 0, err
+	}
+	if len(explain) == 0 {
+		return 0, fmt.Errorf("getql: no plan in %s", output)
+	}
+	return int(explain[0].Plan.Rows), nil
+}
+
+// TotalText is Total for display, which is "1000+" for a capped total, "~1000"
+// for an estimate and empty when the total is unknown
+func (stats SelectStats) TotalText() string {
+	switch {
+	case stats.TotalUnknown:
+		return ""
+	case stats.TotalInexact && stats.Count == CountEstimated:
+		return "~" + strconv.Itoa(stats.Total)
+	case stats.TotalInexact:
+		return strconv.Itoa(stats.Total) + "+"
+	}
+	return strconv.Itoa(stats.Total)
+}
+
+// PageNumbers returns the page numbers from around pages before the current
+// page to around pages after it. The pages stop at TotalPages only if the total
+// is known and exact. There are no page numbers for CountNone, which only has
+// HasPrev and HasNext.
+func (stats SelectStats) PageNumbers(around int) []int {
+	if stats.Count == CountNone {
+		return nil
+	}
+	first, last := stats.Page-around, stats.Page+around
+	if first < 1 {
+		first = 1
+	}
+	if !stats.TotalUnknown && !stats.TotalInexact && last > stats.TotalPages {
+		last = stats.TotalPages
+	}
+	var pages []int
+	for page := first; page <= last; page++ {
+		pages = append(pages, page)
+	}
+	return pages
+}
+
+// countStrategy returns the strategy for counting sq, falling back to
+// CountExact if the configured one can't be used
+func (config SelectStatsConfig) countStrategy(sq SelectQuery) CountStrategy {
+	switch config.Count {
+	case CountCapped:
+		if config.CountCap <= 0 {
+			return CountExact
+		}
+	case CountEstimated:
+		if _, ok := sq.dialect().(Estimator); !ok {
+			return CountExact
+		}
+	}
+	return config.Count
+}
+
+// count fills in stats.Total and stats.TotalPages, or stats.TotalUnknown if
+// the count times out
+func (config SelectStatsConfig) count(ctx context.Context, db Queryer, sq SelectQuery, stats *SelectStats) error {
+	strategy := config.countStrategy(sq)
+	if strategy == CountWindow {
+		strategy = CountExact
+	}
+	countCtx, cancel := config.withTimeout(ctx, config.CountTimeout)
+	defer cancel()
+	total, err := config.total(countCtx, db, sq, strategy)
+	if err != nil {
+		if ctx.Err() == nil && countCtx.Err() == context.DeadlineExceeded {
+			stats.TotalUnknown = true
+			return nil
+		}
+		return err
+	}
+	config.setTotal(stats, strategy, total)
+	return nil
+}
+
+// setTotal fills in stats from the total counted with strategy
+func (config SelectStatsConfig) setTotal(stats *SelectStats, strategy CountStrategy, total int) {
+	stats.Count = strategy
+	switch {
+	case strategy == CountCapped && total > config.CountCap:
+		total = config.CountCap
+		stats.TotalInexact = true
+	case strategy == CountEstimated:
+		stats.TotalInexact = true
+	}
+	stats.Total = total
+	stats.TotalPages = int(math.Ceil(float64(stats.Total) / float64(stats.Limit)))
+	stats.HasNext = stats.Page < stats.TotalPages || stats.TotalInexact
+}
+
+// total returns the number of rows matched by sq, from the cache if possible
+func (config SelectStatsConfig) total(ctx context.Context, db Queryer, sq SelectQuery, strategy CountStrategy) (total int, err error) {
+	if total, ok := config.cachedTotal(sq, strategy); ok {
+		return total, nil
+	}
+	if strategy == CountEstimated {
+		query, args := sq.Sql(unpaged)
+		total, err = sq.dialect().(Estimator).EstimateRows(ctx, db, query, args)
+	} else {
+		query, args := config.countSql(sq, strategy)
+		err = db.QueryRowContext(ctx, query, args...).Scan(&total)
+	}
+	if err != nil {
+		return total, err
+	}
+	config.cacheTotal(sq, strategy, total)
+	return total, nil
+}
+
+// unpaged selects every row matched by the query
+var unpaged SelectOption = func(sq SelectQuery) SelectQuery {
+	sq.Select = []string{"*"}
+	sq.OrderBys = nil
+	sq.Limit = 0
+	sq.Offset = 0
+	return sq
+}
+
+// countSql returns the query counting the rows matched by sq. A capped count
+// counts one row more than the cap, to tell if there are more.
+func (config SelectStatsConfig) countSql(sq SelectQuery, strategy CountStrategy) (query string, args []interface{}) {
+	if strategy != CountCapped {
+		return sq.Sql(SelectCount)
+	}
+	query, args = sq.Sql(unpaged, func(sq SelectQuery) SelectQuery {
+		sq.Limit = config.CountCap + 1
+		return sq
+	})
+	return "SELECT COUNT(*) FROM (" + query + ") AS getql_capped", args
+}
+
+// beforePage counts the total ahead of the page query, unless the page query
+// tells it: a window count reads the total off the page. If the total is left
+// unknown, by CountNone or by a count that timed out, the page query reads one
+// row more than the page to tell if there is a next page. It returns the
+// options for the page query, and whether it selects the window count.
+func (config SelectStatsConfig) beforePage(ctx context.Context, db Queryer, sq SelectQuery, stats *SelectStats) (options []SelectOption, window bool, err error) {
+	switch config.Count {
+	case CountNone:
+		stats.Count = CountNone
+		stats.TotalUnknown = true
+	case CountWindow:
+		if total, ok := config.cachedTotal(sq, CountWindow); ok {
+			config.setTotal(stats, CountWindow, total)
+			return options, false, nil
+		}
+		options = append(options, SelectWindowCount)
+		return options, true, nil
+	default:
+		err = config.count(ctx, db, sq, stats)
+	}
+	if stats.TotalUnknown {
+		options = append(options, func(sq SelectQuery) SelectQuery {
+			if sq.Limit > 0 {
+				sq.Limit++
+			}
+			return sq
+		})
+	}
+	return options, false, err
+}
+
+// afterPage fills in stats from the n rows read by the page query, whose
+// window count is total if window is set. It returns how many of the rows
+// belong on the page.
+func (config SelectStatsConfig) afterPage(ctx context.Context, db Queryer, sq SelectQuery, stats *SelectStats, n int, window bool, total int) (keep int, err error) {
+	switch {
+	case !window && stats.TotalUnknown:
+		if sq.Limit > 0 && n > sq.Limit {
+			stats.HasNext = true
+			return sq.Limit, nil
+		}
+	case window && n == 0 && sq.Offset > 0:
+		// An empty page has no rows to read the total from
+		err = config.count(ctx, db, sq, stats)
+	case window:
+		config.cacheTotal(sq, CountWindow, total)
+		config.setTotal(stats, CountWindow, total)
+	}
+	return n, err
+}
+
+// pageKey is the cache key for the page of sq. If the total is unknown, the
+// page is cached along with the extra row that tells if there is a next page.
+func (config SelectStatsConfig) pageKey(sq SelectQuery, stats SelectStats) string {
+	if stats.TotalUnknown {
+		return "getql:next:" + sq.Fingerprint()
+	}
+	return "getql:page:" + sq.Fingerprint()
+}
+
+// countKey is the cache key for the total of sq. Exact and window counts are
+// interchangeable, but capped and estimated counts are not.
+func (config SelectStatsConfig) countKey(sq SelectQuery, strategy CountStrategy) string {
+	key := "getql:count:"
+	switch strategy {
+	case CountCapped:
+		key += "capped" + strconv.Itoa(config.CountCap) + ":"
+	case CountEstimated:
+		key += "estimated:"
+	}
+	return key + SelectCount(sq.Clone()).Fingerprint()
+}
+
+func (config SelectStatsConfig) cachedTotal(sq SelectQuery, strategy CountStrategy) (total int, ok bool) {
+	if config.Cache == nil || config.CountTTL <= 0 {
+		return 0, false
+	}
+	cached, ok := config.Cache.Get(config.countKey(sq, strategy))
+	if !ok {
+		return 0, false
+	}
+	return cached.(int), true
+}
+
+func (config SelectStatsConfig) cacheTotal(sq SelectQuery, strategy CountStrategy, total int) {
+	if config.Cache != nil && config.CountTTL > 0 {
+		config.Cache.Set(config.countKey(sq, strategy), total, config.CountTTL)
+	}
+}
@@ -0,0 +1,118 @@
+package getql
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestCountStrategies(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+	params := map[string][]string{
+		Sel:      []string{"id"},
+		Frm:      []string{"fruits"},
+		Col("1"): []string{"color"},
+		Opr("1"): []string{In},
+		Val("1"): []string{"red", "green"},
+	}
+	tests := []struct {
+		option   SelectStatsOption
+		total    int
+		inexact  bool
+		count    CountStrategy
+		text     string
+		lastPage int
+	}{
+		{SelectStatsCount(CountExact), 8, false, CountExact, "8", 2},
+		{SelectStatsCountCapped(3), 3, true, CountCapped, "3+", 1},
+		{SelectStatsCountCapped(8), 8, false, CountCapped, "8", 2},
+		// SQLite can't estimate, so it is counted exactly
+		{SelectStatsCount(CountEstimated), 8, false, CountExact, "8", 2},
+	}
+	for i, tt := range tests {
+		_, stats, err := DBSelectMapsWithStats(db, params, SelectStatsDialect(SQLite), tt.option)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stats.Total != tt.total || stats.TotalInexact != tt.inexact || stats.Count != tt.count || stats.TotalText() != tt.text || stats.TotalPages != tt.lastPage {
+			t.Errorf("%d: got stats %+v, text %q", i, stats, stats.TotalText())
+		}
+	}
+}
+
+func TestCountSql(t *testing.T) {
+	sq := SelectQuery{Select: []string{"id"}, From: "fruits", Limit: 5, Offset: 10}
+	config := SelectStatsConfig{CountCap: 100}
+	query, _ := config.countSql(sq, CountCapped)
+	want := `SELECT COUNT(*) FROM (SELECT * FROM "fruits" LIMIT 101) AS getql_capped`
+	if query != want {
+		t.Errorf("got %q, want %q", query, want)
+	}
+	query, _ = config.countSql(sq, CountExact)
+	want = `SELECT COUNT(*) FROM "fruits"`
+	if query != want {
+		t.Errorf("got %q, want %q", query, want)
+	}
+}
+
+func TestPageNumbers(t *testing.T) {
+	tests := []struct {
+		stats SelectStats
+		want  string
+	}{
+		{SelectStats{Page: 1, TotalPages: 10}, "[1 2 3]"},
+		{SelectStats{Page: 5, TotalPages: 10}, "[3 4 5 6 7]"},
+		{SelectStats{Page: 10, TotalPages: 10}, "[8 9 10]"},
+		{SelectStats{Page: 10, TotalPages: 10, TotalInexact: true}, "[8 9 10 11 12]"},
+		{SelectStats{Page: 4, TotalUnknown: true}, "[2 3 4 5 6]"},
+	}
+	for _, tt := range tests {
+		if got := fmt.Sprint(tt.stats.PageNumbers(2)); got != tt.want {
+			t.Errorf("%+v: got %s, want %s", tt.stats, got, tt.want)
+		}
+	}
+}
+
+func TestNoCount(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+	params := map[string][]string{
+		Sel:      []string{"id"},
+		Frm:      []string{"fruits"},
+		Col("1"): []string{"color"},
+		Opr("1"): []string{In},
+		Val("1"): []string{"red", "green"},
+		Ord("1"): []string{"id", Asc},
+	}
+	tests := []struct {
+		page    string
+		results int
+		hasPrev bool
+		hasNext bool
+	}{
+		{"1", 5, false, true},
+		{"2", 3, true, false},
+	}
+	cache := NewLRUCache(8)
+	for _, tt := range tests {
+		params[Page] = []string{tt.page}
+		// The second run reads the page from the cache
+		for i := 0; i < 2; i++ {
+			results, stats, err := DBSelectMapsWithStats(db, params, SelectStatsDialect(SQLite), SelectStatsNoCount, SelectStatsCache(cache, 0, time.Minute))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(results) != tt.results || stats.HasPrev != tt.hasPrev || stats.HasNext != tt.hasNext {
+				t.Errorf("page %s: got %d results, stats %+v", tt.page, len(results), stats)
+			}
+			if !stats.TotalUnknown || stats.TotalPages != 0 || stats.PageNumbers(2) != nil {
+				t.Errorf("page %s: got stats %+v", tt.page, stats)
+			}
+		}
+	}
+	_, _, err := DBSelectWithStats(db, params, SelectStatsDialect(SQLite), SelectStatsNoCount)
+	if err == nil {
+		t.Error("DBSelectWithStats accepted SelectStatsNoCount")
+	}
+}
@@ -0,0 +1,249 @@
+package getql
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Dialect controls the parts of the generated SQL that differ between
+// databases.
+type Dialect interface {
+	// Placeholder returns the bind parameter for the nth (1-based) argument
+	Placeholder(n int) string
+	// QuoteIdent quotes a single (unqualified) identifier
+	QuoteIdent(ident string) string
+	// ILike returns a case-insensitive LIKE comparison of column against a ?
+	// placeholder
+	ILike(column string) string
+	// LimitOffset returns the LIMIT/OFFSET clause, or an empty string if
+	// neither limit nor offset is set. ordered reports whether the query
+	// already has an ORDER BY clause.
+	LimitOffset(limit, offset int, ordered bool) string
+	// Bool returns the boolean literal for b
+	Bool(b bool) string
+	// QuoteString returns s as an escaped string literal
+	QuoteString(s string) string
+	// QuoteBytes returns b as a binary string literal
+	QuoteBytes(b []byte) string
+	// QuoteTime returns t as a timestamp literal
+	QuoteTime(t time.Time) string
+	// OrderBy returns the ORDER BY term for column. order is "ASC" or "DESC",
+	// nulls is "FIRST", "LAST" or empty.
+	OrderBy(column, order, nulls string) string
+}
+
+// Built-in dialects
+var (
+	Postgres  Dialect = postgres{}
+	MySQL     Dialect = mysql{}
+	SQLite    Dialect = sqlite{}
+	SQLServer Dialect = sqlserver{}
+)
+
+type postgres struct{}
+
+func (postgres) Placeholder(n int) string       { return "$" + strconv.Itoa(n) }
+func (postgres) QuoteIdent(ident string) string { return quoteIdent(ident, `"`, `"`) }
+func (postgres) ILike(column string) string     { return column + " ILIKE ?" }
+func (postgres) Bool(b bool) string             { return boolKeyword(b) }
+
+func (postgres) LimitOffset(limit, offset int, ordered bool) string {
+	return limitOffset(limit, offset, "")
+}
+
+func (postgres) OrderBy(column, order, nulls string) string {
+	return nullsKeyword(column, order, nulls)
+}
+
+type mysql struct{}
+
+func (mysql) Placeholder(n int) string       { return "?" }
+func (mysql) QuoteIdent(ident string) string { return quoteIdent(ident, "`", "`") }
+func (mysql) ILike(column string) string     { return lowerLike(column) }
+func (mysql) Bool(b bool) string             { return boolKeyword(b) }
+
+// MySQL does not support OFFSET without LIMIT, so the largest possible LIMIT
+// is used instead
+func (mysql) LimitOffset(limit, offset int, ordered bool) string {
+	return limitOffset(limit, offset, "18446744073709551615")
+}
+
+func (mysql) OrderBy(column, order, nulls string) string {
+	return nullsCase(column, order, nulls)
+}
+
+type sqlite struct{}
+
+func (sqlite) Placeholder(n int) string       { return "?" }
+func (sqlite) QuoteIdent(ident string) string { return quoteIdent(ident, `"`, `"`) }
+func (sqlite) ILike(column string) string     { return lowerLike(column) }
+func (sqlite) Bool(b bool) string             { return boolInt(b) }
+
+// SQLite does not support OFFSET without LIMIT, a negative LIMIT means no
+// limit
+func (sqlite) LimitOffset(limit, offset int, ordered bool) string {
+	return limitOffset(limit, offset, "-1")
+}
+
+func (sqlite) OrderBy(column, order, nulls string) string {
+	return nullsKeyword(column, order, nulls)
+}
+
+type sqlserver struct{}
+
+func (sqlserver) Placeholder(n int) string       { return "@p" + strconv.Itoa(n) }
+func (sqlserver) QuoteIdent(ident string) string { return quoteIdent(ident, "[", "]") }
+func (sqlserver) ILike(column string) string     { return lowerLike(column) }
+func (sqlserver) Bool(b bool) string             { return boolInt(b) }
+
+// SQL Server pages with OFFSET ... FETCH, which is only allowed after an
+// ORDER BY
+func (sqlserver) LimitOffset(limit, offset int, ordered bool) string {
+	if limit == 0 && offset == 0 {
+		return ""
+	}
+	buf := &strings.Builder{}
+	if !ordered {
+		buf.WriteString("ORDER BY (SELECT NULL)" + space)
+	}
+	buf.WriteString("OFFSET" + space + strconv.Itoa(offset) + space + "ROWS")
+	if limit != 0 {
+		buf.WriteString(space + "FETCH NEXT" + space + strconv.Itoa(limit) + space + "ROWS ONLY")
+	}
+	return buf.String()
+}
+
+func (sqlserver) OrderBy(column, order, nulls string) string {
+	return nullsCase(column, order, nulls)
+}
+
+func quoteIdent(ident, open, close string) string {
+	return open + strings.ReplaceAll(ident, close, close+close) + close
+}
+
+func lowerLike(column string) string {
+	return "LOWER(" + column + ") LIKE LOWER(?)"
+}
+
+func boolKeyword(b bool) string {
+	if b {
+		return "TRUE"
+	}
+	return "FALSE"
+}
+
+func boolInt(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
+
+// limitOffset returns "LIMIT n OFFSET m". noLimit is the LIMIT used when only
+// an offset is given, or empty if the dialect allows a bare OFFSET.
+func limitOffset(limit, offset int, noLimit string) string {
+	buf := &strings.Builder{}
+	if limit != 0 {
+		buf.WriteString("LIMIT" + space + strconv.Itoa(limit))
+	} else if offset != 0 && noLimit != "" {
+		buf.WriteString("LIMIT" + space + noLimit)
+	}
+	if offset != 0 {
+		if buf.Len() > 0 {
+			buf.WriteString(space)
+		}
+		buf.WriteString("OFFSET" + space + strconv.Itoa(offset))
+	}
+	return buf.String()
+}
+
+// nullsKeyword orders NULLs with the standard NULLS FIRST/NULLS LAST
+func nullsKeyword(column, order, nulls string) string {
+	term := column + space + order
+	if nulls != "" {
+		term += space + "NULLS" + space + nulls
+	}
+	return term
+}
+
+// nullsCase emulates NULLS FIRST/NULLS LAST for databases that don't support
+// it by sorting on whether the column is NULL first
+func nullsCase(column, order, nulls string) string {
+	term := column + space + order
+	switch nulls {
+	case First:
+		term = "CASE WHEN " + column + " IS NULL THEN 0 ELSE 1 END," + space + term
+	case Last:
+		term = "CASE WHEN " + column + " IS NULL THEN 1 ELSE 0 END," + space + term
+	}
+	return term
+}
+
+// ident quotes a possibly schema-qualified name such as schema.table.column
+// for d, and escapes any ? so that it isn't mistaken for a placeholder. Parts
+// that are already quoted are requoted for d, while *, COUNT(*) and
+// WindowCount are left as is.
+func ident(d Dialect, name string) string {
+	if name == "*" || name == Count || name == WindowCount {
+		return name
+	}
+	parts := splitQualified(name)
+	for i, part := range parts {
+		if part == "" {
+			parts = []string{d.QuoteIdent(name)}
+			break
+		}
+		if part == "*" && i == len(parts)-1 {
+			continue
+		}
+		parts[i] = d.QuoteIdent(unquote(part))
+	}
+	return strings.ReplaceAll(strings.Join(parts, "."), "?", "??")
+}
+
+// splitQualified splits name on dots that are not inside double quotes,
+// backticks or square brackets
+func splitQualified(name string) (parts []string) {
+	var closer rune
+	start := 0
+	for i, r := range name {
+		switch {
+		case closer != 0:
+			if r == closer {
+				closer = 0
+			}
+		case r == '"' || r == '`':
+			closer = r
+		case r == '[':
+			closer = ']'
+		case r == '.':
+			parts = append(parts, name[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, name[start:])
+}
+
+// unquote removes the quotes around an identifier quoted with "", “ or [],
+// unescaping any doubled closing quotes
+func unquote(part string) string {
+	if len(part) < 2 {
+		return part
+	}
+	var close string
+	switch part[0] {
+	case '"':
+		close = `"`
+	case '`':
+		close = "`"
+	case '[':
+		close = "]"
+	default:
+		return part
+	}
+	if part[len(part)-1:] != close {
+		return part
+	}
+	return strings.ReplaceAll(part[1:len(part)-1], close+close, close)
+}
@@ -0,0 +1,78 @@
+package getql
+
+import (
+	"testing"
+)
+
+func TestDialects(t *testing.T) {
+	params := map[string][]string{
+		Sel:      []string{"a", "b"},
+		Frm:      []string{"tabel"},
+		Col("1"): []string{"A"},
+		Opr("1"): []string{Eq},
+		Val("1"): []string{"x"},
+		Col("2"): []string{"B"},
+		Opr("2"): []string{ILike},
+		Val("2"): []string{"%y%"},
+		Ord("1"): []string{"a", Desc, NullsLast},
+		Lim:      []string{"10"},
+		Off:      []string{"20"},
+	}
+	tests := []struct {
+		dialect Dialect
+		want    string
+	}{
+		{Postgres, `SELECT "a", "b" FROM "tabel" WHERE "A" = $1 AND "B" ILIKE $2 ORDER BY "a" DESC NULLS LAST LIMIT 10 OFFSET 20`},
+		{MySQL, "SELECT `a`, `b` FROM `tabel` WHERE `A` = ? AND LOWER(`B`) LIKE LOWER(?) ORDER BY CASE WHEN `a` IS NULL THEN 1 ELSE 0 END, `a` DESC LIMIT 10 OFFSET 20"},
+		{SQLite, `SELECT "a", "b" FROM "tabel" WHERE "A" = ? AND LOWER("B") LIKE LOWER(?) ORDER BY "a" DESC NULLS LAST LIMIT 10 OFFSET 20`},
+		{SQLServer, "SELECT [a], [b] FROM [tabel] WHERE [A] = @p1 AND LOWER([B]) LIKE LOWER(@p2) ORDER BY CASE WHEN [a] IS NULL THEN 1 ELSE 0 END, [a] DESC OFFSET 20 ROWS FETCH NEXT 10 ROWS ONLY"},
+	}
+	for _, tt := range tests {
+		query, args := ParseSelect(params).Sql(WithDialect(tt.dialect))
+		if query != tt.want {
+			t.Errorf("%T: got %q, want %q", tt.dialect, query, tt.want)
+		}
+		if len(args) != 2 {
+			t.Errorf("%T: got %d args, want 2", tt.dialect, len(args))
+		}
+	}
+}
+
+func TestLimitOffset(t *testing.T) {
+	tests := []struct {
+		dialect Dialect
+		want    string
+	}{
+		{Postgres, "OFFSET 5"},
+		{MySQL, "LIMIT 18446744073709551615 OFFSET 5"},
+		{SQLite, "LIMIT -1 OFFSET 5"},
+		{SQLServer, "ORDER BY (SELECT NULL) OFFSET 5 ROWS"},
+	}
+	for _, tt := range tests {
+		if got := tt.dialect.LimitOffset(0, 5, false); got != tt.want {
+			t.Errorf("%T: got %q, want %q", tt.dialect, got, tt.want)
+		}
+	}
+}
+
+func TestIdent(t *testing.T) {
+	tests := []struct {
+		dialect Dialect
+		name    string
+		want    string
+	}{
+		{Postgres, "user", `"user"`},
+		{Postgres, "public.Orders", `"public"."Orders"`},
+		{Postgres, `"my.schema".t.*`, `"my.schema"."t".*`},
+		{Postgres, `we"ird?`, `"we""ird??"`},
+		{Postgres, Count, Count},
+		{MySQL, "`order`", "`order`"},
+		{SQLServer, `dbo."group"`, "[dbo].[group]"},
+		{SQLServer, "a]b", "[a]]b]"},
+	}
+	for _, tt := range tests {
+		if got := ident(tt.dialect, tt.name); got != tt.want {
+			t.Errorf("%T %q: got %q, want %q", tt.dialect, tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package getql
+
+import (
+	"encoding/csv"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type CSVHandlerConfig struct {
+	StatsOptions []SelectStatsOption                 // Only the dialect, parser and scope are used
+	Role         func(*http.Request) string          // The role the schema is authorized for
+	Scope        func(*http.Request) Scope           // Added to the scope of every query
+	Filename     func(*http.Request) string          // Defaults to the table name with .csv, or export.csv
+	MaxRows      int                                 // The most rows exported, 0 for no limit
+	Header       func(column Column) string          // Defaults to the column's label, or its name
+	Formats      map[string]func(interface{}) string // Formats the values of a column, by name
+	ErrorHandler ErrorHandler                        // Passed the errors behind a 500 or a cut short export
+}
+
+type CSVHandlerOption func(CSVHandlerConfig) CSVHandlerConfig
+
+// CSVHandlerStats passes options on to the query, see CSVHandlerConfig
+func CSVHandlerStats(options ...SelectStatsOption) CSVHandlerOption {
+	return func(config CSVHandlerConfig) CSVHandlerConfig {
+		config.StatsOptions = append(config.StatsOptions[:len(config.StatsOptions):len(config.StatsOptions)], options...)
+		return config
+	}
+}
+
+func CSVHandlerRole(role func(*http.Request) string) CSVHandlerOption {
+	return func(config CSVHandlerConfig) CSVHandlerConfig {
+		config.Role = role
+		return config
+	}
+}
+
+func CSVHandlerScope(scope func(*http.Request) Scope) CSVHandlerOption {
+	return func(config CSVHandlerConfig) CSVHandlerConfig {
+		config.Scope = scope
+		return config
+	}
+}
+
+func CSVHandlerFilename(filename func(*http.Request) string) CSVHandlerOption {
+	return func(config CSVHandlerConfig) CSVHandlerConfig {
+		config.Filename = filename
+		return config
+	}
+}
+
+func CSVHandlerMaxRows(maxRows int) CSVHandlerOption {
+	return func(config CSVHandlerConfig) CSVHandlerConfig {
+		config.MaxRows = maxRows
+		return config
+	}
+}
+
+func CSVHandlerHeader(header func(column Column) string) CSVHandlerOption {
+	return func(config CSVHandlerConfig) CSVHandlerConfig {
+		config.Header = header
+		return config
+	}
+}
+
+func CSVHandlerErrorHandler(errorHandler ErrorHandler) CSVHandlerOption {
+	return func(config CSVHandlerConfig) CSVHandlerConfig {
+		config.ErrorHandler = errorHandler
+		return config
+	}
+}
+
+// CSVHandlerFormat formats the values of column with format
+func CSVHandlerFormat(column string, format func(interface{}) string) CSVHandlerOption {
+	return func(config CSVHandlerConfig) CSVHandlerConfig {
+		formats := make(map[string]func(interface{}) string, len(config.Formats)+1)
+		for name, f := range config.Formats {
+			formats[name] = f
+		}
+		formats[column] = format
+		config.Formats = formats
+		return config
+	}
+}
+
+// CSVHandler streams every row of schema's table that matches the getql
+// parameters of the request as CSV, ignoring the limit, offset and page. Rows
+// are written as they are read from the database. An export that is cut short,
+// by MaxRows or by an error, ends in a row that says so. Cells that a
+// spreadsheet would run as a formula are prefixed with a quote.
+func CSVHandler(db Queryer, schema Schema, options ...CSVHandlerOption) http.Handler {
+	var config CSVHandlerConfig
+	for _, option := range options {
+		config = option(config)
+	}
+	statsConfig := newSelectStatsConfig(config.StatsOptions)
+	p := statsConfig.Parser
+	handleError := func(r *http.Request, err error) {
+		if config.ErrorHandler != nil {
+			config.ErrorHandler(r, err)
+		}
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := r.ParseForm()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		params := p.ScrubForm(cloneForm(r.Form))
+		params.Del(p.Key(p.Suffixes.Lim))
+		params.Del(p.Key(p.Suffixes.Off))
+		params.Del(p.Key(p.Page))
+		var role string
+		if config.Role != nil {
+			role = config.Role(r)
+		}
+		sq, err := p.Parse(params)
+		if err == nil {
+			sq, err = schema.AuthorizeWith(p, sq, role)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		sq.Dialect = statsConfig.Dialect
+		sq.Scope = statsConfig.Scope
+		if config.Scope != nil {
+			sq.Scope = append(sq.Scope[:len(sq.Scope):len(sq.Scope)], config.Scope(r)...)
+		}
+		if err = sq.Scope.Validate(); err != nil {
+			handleError(r, err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		if config.MaxRows > 0 {
+			// The extra row tells if the export is truncated
+			sq.Limit = config.MaxRows + 1
+		}
+		query, args := sq.Sql()
+		rows, err := db.QueryContext(r.Context(), query, args...)
+		if err != nil {
+			handleError(r, err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+		columns, err := rows.Columns()
+		if err != nil {
+			handleError(r, err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		filename := "export.csv"
+		if schema.Table != "" {
+			filename = schema.Table + ".csv"
+		}
+		if config.Filename != nil {
+			filename = config.Filename(r)
+		}
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+		cw := csv.NewWriter(w)
+		record := make([]string, len(columns))
+		for i, name := range columns {
+			c, ok := schema.Column(name)
+			if !ok {
+				c = Column{Name: name}
+			}
+			switch {
+			case config.Header != nil:
+				record[i] = escapeCSV(config.Header(c))
+			case c.Label != "":
+				record[i] = escapeCSV(c.Label)
+			default:
+				record[i] = escapeCSV(c.Name)
+			}
+		}
+		err = cw.Write(record)
+		values := make([]interface{}, len(columns))
+		dest := make([]interface{}, len(columns))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		// The status has been sent, so from here on a failure can only cut
+		// the export short
+		n, truncated := 0, false
+		for err == nil && rows.Next() {
+			if config.MaxRows > 0 && n == config.MaxRows {
+				truncated = true
+				break
+			}
+			err = rows.Scan(dest...)
+			if err != nil {
+				break
+			}
+			for i, value := range values {
+				if format := config.Formats[columns[i]]; format != nil {
+					record[i] = escapeCSV(format(value))
+				} else {
+					record[i] = escapeCSV(formatCSV(value))
+				}
+			}
+			err = cw.Write(record)
+			n++
+		}
+		if err == nil {
+			err = rows.Err()
+		}
+		switch {
+		case err != nil:
+			handleError(r, err)
+			cw.Write(trailerCSV(len(columns), "(export incomplete: an error occurred)"))
+		case truncated:
+			cw.Write(trailerCSV(len(columns), fmt.Sprintf("(export truncated at %d rows)", config.MaxRows)))
+		}
+		cw.Flush()
+		if err == nil && cw.Error() != nil {
+			handleError(r, cw.Error())
+		}
+	})
+}
+
+// trailerCSV returns the row of width columns that ends an export cut short
+func trailerCSV(width int, message string) []string {
+	if width < 1 {
+		width = 1
+	}
+	record := make([]string, width)
+	record[0] = message
+	return record
+}
+
+// escapeCSV prefixes a quote to a cell that a spreadsheet would otherwise run
+// as a formula. Numbers like -1 are left alone.
+func escapeCSV(cell string) string {
+	if cell == "" || !strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+		return cell
+	}
+	if _, err := strconv.ParseFloat(cell, 64); err == nil {
+		return cell
+	}
+	return "'" + cell
+}
+
+// formatCSV formats a value scanned from the database for CSV
+func formatCSV(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.Format(time.RFC3339)
+	}
+	return fmt.Sprint(value)
+}
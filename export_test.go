@@ -0,0 +1,103 @@
+package getql
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestCSVHandler(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+	schema := Schema{
+		Table: "fruits",
+		Columns: []Column{
+			{Name: "id", Label: "ID", Perm: PermAll},
+			{Name: "name", Label: "Fruit, named", Perm: PermAll},
+			{Name: "color", Perm: PermAll},
+		},
+	}
+	handler := CSVHandler(db, schema,
+		CSVHandlerStats(SelectStatsDialect(SQLite)),
+		CSVHandlerMaxRows(3),
+		CSVHandlerFilename(func(r *http.Request) string { return "red fruits.csv" }),
+		CSVHandlerFormat("id", func(v interface{}) string { return fmt.Sprintf("#%v", v) }),
+	)
+	query := url.Values{
+		Sel:      []string{"id", "name", "color"},
+		Col("1"): []string{"color"},
+		Opr("1"): []string{Eq},
+		Val("1"): []string{"red"},
+		Ord("1"): []string{"id", Desc},
+		Lim:      []string{"1"},
+		Page:     []string{"4"},
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/export?"+query.Encode(), nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d: %s", w.Code, w.Body)
+	}
+	want := "ID,\"Fruit, named\",color\n#12,fruit12,red\n#9,fruit9,red\n#6,fruit6,red\n(export truncated at 3 rows),,\n"
+	if w.Body.String() != want {
+		t.Errorf("got %q, want %q", w.Body.String(), want)
+	}
+	if got := w.Header().Get("Content-Disposition"); got != `attachment; filename="red fruits.csv"` {
+		t.Errorf("got Content-Disposition %s", got)
+	}
+	query.Set(Col("1"), "price")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/export?"+query.Encode(), nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("got status %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestCSVHandlerEscaping(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+	schema := Schema{Columns: []Column{{Name: "id", Perm: PermAll}, {Name: "name", Perm: PermAll}}}
+	handler := CSVHandler(db, schema,
+		CSVHandlerStats(SelectStatsDialect(SQLite)),
+		CSVHandlerFormat("id", func(v interface{}) string { return fmt.Sprintf("-%v", v) }),
+		CSVHandlerFormat("name", func(v interface{}) string { return fmt.Sprintf("=HYPERLINK(%q)", v) }),
+	)
+	query := url.Values{
+		Frm:      []string{"fruits"},
+		Sel:      []string{"id", "name"},
+		Col("1"): []string{"id"},
+		Opr("1"): []string{Eq},
+		Val("1"): []string{"1"},
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/export?"+query.Encode(), nil))
+	want := "id,name\n-1,\"'=HYPERLINK(\"\"fruit1\"\")\"\n"
+	if w.Body.String() != want {
+		t.Errorf("got %q, want %q", w.Body.String(), want)
+	}
+	if got := w.Header().Get("Content-Disposition"); got != `attachment; filename=export.csv` {
+		t.Errorf("got Content-Disposition %s", got)
+	}
+}
+
+func TestCSVHandlerError(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+	schema := Schema{Table: "missing", Columns: []Column{{Name: "id", Perm: PermAll}}}
+	var handled error
+	handler := CSVHandler(db, schema,
+		CSVHandlerStats(SelectStatsDialect(SQLite)),
+		CSVHandlerErrorHandler(func(r *http.Request, err error) {
+			handled = err
+		}),
+	)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/export", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("got status %d: %s", w.Code, w.Body)
+	}
+	if handled == nil {
+		t.Errorf("the error handler wasn't passed the error")
+	}
+}
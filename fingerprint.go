@@ -0,0 +1,59 @@
+package getql
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+)
+
+// Fingerprint returns a hash of the normalized query, including its values.
+// Queries that normalize to the same SQL and arguments have the same
+// fingerprint, which makes it usable as a cache key or ETag.
+func (sq SelectQuery) Fingerprint() string {
+	return hash(sq.canonical(true))
+}
+
+// Shape returns a hash of the normalized query without its values, limit and
+// offset, so that queries which only differ in their values (including the
+// number of IN values) have the same shape. Unlike Normalize, Shape doesn't
+// turn IN with a single value into EQ.
+func (sq SelectQuery) Shape() string {
+	return hash(sq.canonical(false))
+}
+
+func hash(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// canonical returns a string that is the same for queries that normalize to
+// the same SQL
+func (sq SelectQuery) canonical(withValues bool) string {
+	sq = sq.normalize(!withValues)
+	buf := &strings.Builder{}
+	buf.WriteString("SELECT " + strings.Join(quoteAll(sq.Select), ","))
+	buf.WriteString("\nFROM " + strconv.Quote(sq.From))
+	if sq.Where != nil {
+		buf.WriteString("\nWHERE " + canonicalPred(&Pred{Nested: true, PredGrp: sq.Where}, withValues))
+	}
+	if len(sq.Scope) > 0 {
+		scope := make(map[string]*Pred, len(sq.Scope))
+		for i := range sq.Scope {
+			scope[strconv.Itoa(i)] = &sq.Scope[i]
+		}
+		scopeGrp := (&PredGrp{Preds: scope}).normalize(!withValues)
+		buf.WriteString("\nSCOPE " + canonicalPred(&Pred{Nested: true, PredGrp: scopeGrp}, withValues))
+	}
+	var orderBys []string
+	for _, orderby := range sq.OrderBys {
+		if orderby.String() != "" {
+			orderBys = append(orderBys, strconv.Quote(orderby.Column)+space+orderby.Order+space+orderby.Nulls)
+		}
+	}
+	buf.WriteString("\nORDER BY " + strings.Join(orderBys, ","))
+	if withValues {
+		buf.WriteString("\nLIMIT " + strconv.Itoa(sq.Limit) + " OFFSET " + strconv.Itoa(sq.Offset))
+	}
+	return buf.String()
+}
@@ -0,0 +1,77 @@
+package getql
+
+import (
+	"testing"
+)
+
+func TestFingerprint(t *testing.T) {
+	a := ParseSelect(map[string][]string{
+		Frm:      []string{"t"},
+		Col("1"): []string{"a"},
+		Opr("1"): []string{In},
+		Val("1"): []string{"x", "y"},
+		Col("2"): []string{"b"},
+		Opr("2"): []string{Eq},
+		Val("2"): []string{"1"},
+	})
+	b := ParseSelect(map[string][]string{
+		Frm:      []string{"t"},
+		Col("1"): []string{"b"},
+		Opr("1"): []string{Eq},
+		Val("1"): []string{"1"},
+		Col("2"): []string{"a"},
+		Opr("2"): []string{In},
+		Val("2"): []string{"y", "x", "x"},
+		Col("3"): []string{"b"},
+		Opr("3"): []string{Eq},
+		Val("3"): []string{"1"},
+	})
+	c := ParseSelect(map[string][]string{
+		Frm:      []string{"t"},
+		Col("1"): []string{"a"},
+		Opr("1"): []string{In},
+		Val("1"): []string{"x", "y", "z"},
+		Col("2"): []string{"b"},
+		Opr("2"): []string{Eq},
+		Val("2"): []string{"2"},
+		Lim:      []string{"10"},
+	})
+	if a.Fingerprint() != b.Fingerprint() {
+		t.Errorf("equivalent queries have different fingerprints")
+	}
+	if a.Fingerprint() == c.Fingerprint() {
+		t.Errorf("different values have the same fingerprint")
+	}
+	if a.Shape() != c.Shape() {
+		t.Errorf("queries with the same shape have different shapes")
+	}
+	single := ParseSelect(map[string][]string{
+		Frm:      []string{"t"},
+		Col("1"): []string{"a"},
+		Opr("1"): []string{In},
+		Val("1"): []string{"x"},
+		Col("2"): []string{"b"},
+		Opr("2"): []string{Eq},
+		Val("2"): []string{"1"},
+	})
+	if a.Shape() != single.Shape() {
+		t.Errorf("IN with one value has a different shape from IN with two values")
+	}
+	eq := ParseSelect(map[string][]string{
+		Frm:      []string{"t"},
+		Col("1"): []string{"a"},
+		Opr("1"): []string{Eq},
+		Val("1"): []string{"x"},
+		Col("2"): []string{"b"},
+		Opr("2"): []string{Eq},
+		Val("2"): []string{"1"},
+	})
+	if single.Fingerprint() != eq.Fingerprint() {
+		t.Errorf("IN with one value has a different fingerprint from EQ")
+	}
+	scoped := a
+	scoped.Scope = Scope{{Column: "tenant_id", Operator: Eq, Value: "1"}}
+	if a.Fingerprint() == scoped.Fingerprint() {
+		t.Errorf("scope doesn't change the fingerprint")
+	}
+}
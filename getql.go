@@ -2,7 +2,7 @@ package getql
 
 import (
 	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
 	"math"
 	"net/http"
@@ -37,26 +37,13 @@ const (
 	Filter = "FILTER"
 )
 
-func IsValidSuffix(suffix string) bool {
-	suffixes := map[string]bool{
-		Sel:  true,
-		Frm:  true,
-		col:  true,
-		opr:  true,
-		val:  true,
-		ord:  true,
-		Lim:  true,
-		aor:  true,
-		Page: true,
-	}
-	return suffixes[suffix]
-}
+func IsValidSuffix(suffix string) bool { return DefaultParser.IsValidSuffix(suffix) }
 
-func Col(strs ...string) string { return strings.Join(append(strs, col), Sep) }
-func Opr(strs ...string) string { return strings.Join(append(strs, opr), Sep) }
-func Val(strs ...string) string { return strings.Join(append(strs, val), Sep) }
-func Ord(strs ...string) string { return strings.Join(append(strs, ord), Sep) }
-func Aor(strs ...string) string { return strings.Join(append(strs, aor), Sep) }
+func Col(strs ...string) string { return DefaultParser.Col(strs...) }
+func Opr(strs ...string) string { return DefaultParser.Opr(strs...) }
+func Val(strs ...string) string { return DefaultParser.Val(strs...) }
+func Ord(strs ...string) string { return DefaultParser.Ord(strs...) }
+func Aor(strs ...string) string { return DefaultParser.Aor(strs...) }
 
 // Notice all the suffixes have the same length, which is so that a fixed
 // number of characters can be chopped off the end of a string to be used in a
@@ -71,11 +58,22 @@ const space = " "
 
 // SQL Keywords
 const (
-	Count = "COUNT(*)"
-	Asc   = "ASC"
-	Desc  = "DESC"
-	And   = "AND"
-	Or    = "OR"
+	Count      = "COUNT(*)"
+	Asc        = "ASC"
+	Desc       = "DESC"
+	First      = "FIRST"
+	Last       = "LAST"
+	NullsFirst = "NULLSFIRST"
+	NullsLast  = "NULLSLAST"
+	And        = "AND"
+	Or         = "OR"
+)
+
+// WindowCount is selected alongside the columns, so that every row carries the
+// total in its WindowCountColumn
+const (
+	WindowCount       = "COUNT(*) OVER() AS " + WindowCountColumn
+	WindowCountColumn = "getql_total"
 )
 
 // Operators
@@ -96,47 +94,24 @@ const (
 )
 
 type OrderBy struct {
-	Column string
-	Order  string // "ASC" or "DESC"
+	Column string `json:"column"`
+	Order  string `json:"order"`           // "ASC" or "DESC"
+	Nulls  string `json:"nulls,omitempty"` // "FIRST", "LAST" or empty
+	param  string // The parameter the OrderBy was parsed from, if any
 }
 
 func (orderby OrderBy) String() string {
 	if orderby.Column == "" || orderby.Order == "" {
 		return ""
 	}
-	return orderby.Column + " " + orderby.Order
+	return nullsKeyword(orderby.Column, orderby.Order, orderby.Nulls)
 }
 
-func ScrubForm(form url.Values) url.Values {
-	for key, _ := range form {
-		strs := strings.Split(key, Sep)
-		suffix := strs[len(strs)-1]
-		if !IsValidSuffix(suffix) {
-			form.Del(key)
-		}
-	}
-	return form
-}
+func ScrubForm(form url.Values) url.Values { return DefaultParser.ScrubForm(form) }
 
-func ScrubRequest(r *http.Request) *http.Request {
-	r.ParseForm()
-	for key, _ := range r.Form {
-		strs := strings.Split(key, Sep)
-		suffix := strs[len(strs)-1]
-		if !IsValidSuffix(suffix) {
-			r.Form.Del(key)
-		}
-	}
-	return r
-}
+func ScrubRequest(r *http.Request) *http.Request { return DefaultParser.ScrubRequest(r) }
 
-func ScrubUrl(url string, form url.Values) string {
-	form = ScrubForm(form)
-	if len(form) > 0 {
-		url += "?" + form.Encode()
-	}
-	return url
-}
+func ScrubUrl(url string, form url.Values) string { return DefaultParser.ScrubUrl(url, form) }
 
 type SelectQuery struct {
 	Select   []string
@@ -145,6 +120,62 @@ type SelectQuery struct {
 	OrderBys []OrderBy
 	Limit    int
 	Offset   int
+	Dialect  Dialect // defaults to Postgres
+	Scope    Scope
+}
+
+// Scope is a list of server side predicates, e.g. tenant_id = 42, that are
+// ANDed with the whole WHERE clause of a query. The user's predicates are
+// rendered as a nested group so that a top level OR can't escape the scope.
+// A malformed predicate, which would otherwise render as nothing, renders as
+// 1 = 0 so that a broken scope matches no rows rather than every row.
+type Scope []Pred
+
+// Validate returns an error if any predicate in scope is malformed, such as
+// one with an unknown operator or a BETWEEN without two values
+func (scope Scope) Validate() error {
+	for i := range scope {
+		if problem := malformed(&scope[i]); problem != "" {
+			return fmt.Errorf("getql: scope predicate %d: %s", i+1, problem)
+		}
+	}
+	return nil
+}
+
+// malformed describes what keeps pred from being rendered, or returns "" if
+// nothing does
+func malformed(pred *Pred) string {
+	if pred.Nested {
+		if pred.PredGrp == nil || len(pred.PredGrp.Preds) == 0 {
+			return "empty group"
+		}
+		for _, key := range sortedKeys(pred.PredGrp.Preds) {
+			if pred.PredGrp.Preds[key] == nil {
+				continue
+			}
+			if problem := malformed(pred.PredGrp.Preds[key]); problem != "" {
+				return problem
+			}
+		}
+		return ""
+	}
+	if pred.Column == "" {
+		return "no column"
+	}
+	switch operator := strings.TrimSpace(pred.Operator); operator {
+	case Eq, Ne, Gt, Ge, Lt, Le, Null, NotNull, Like, ILike:
+	case In:
+		if len(pred.Values) == 0 {
+			return "IN without values"
+		}
+	case Between:
+		if len(dedup(pred.Values)) < 2 {
+			return "BETWEEN without two values"
+		}
+	default:
+		return fmt.Sprintf("unknown operator %q", pred.Operator)
+	}
+	return ""
 }
 
 type PredGrp struct {
@@ -165,103 +196,50 @@ type Pred struct {
 }
 
 func ParseSelect(params map[string][]string) (query SelectQuery) {
-	// Return first string from params[name], or empty string
-	paramvalue := func(name string) (value string) {
-		if values := params[name]; len(values) > 0 {
-			value = values[0]
-		}
-		return value
-	}
-	// Return first string from params[name] converted into int, or 0
-	paramvalueInt := func(name string) (value int) {
-		if values := params[name]; len(values) > 0 {
-			value, _ = strconv.Atoi(values[0]) // Don't care if it fails, zero value is fine
-		}
-		return value
-	}
-	query.Select = dedup(removeEmptyStrings(params[Sel]))
-	query.From = paramvalue(Frm)
-	query.Where = &PredGrp{}
-	query.Limit = paramvalueInt(Lim)
-	query.Offset = paramvalueInt(Off)
-	orderbyMap := make(map[string]OrderBy)
-	orderbyKeys := make([]string, 0)
-	var ref *PredGrp
-	for name, values := range params {
-		strs := strings.Split(name, Sep)
-		if len(strs) == 0 {
-			continue
-		}
-		prefixes := strs[:len(strs)-1]
-		suffix := strs[len(strs)-1]
-		if !IsValidSuffix(suffix) {
-			continue
-		}
-		value := paramvalue(name)
-		values = dedup(values)
-		ref = query.Where
-		switch suffix {
-		case ord:
-			var orderby OrderBy
-			for _, value := range values {
-				if value == Ignore {
-					break
-				}
-				if value == Asc || value == Desc {
-					orderby.Order = value
-				} else {
-					orderby.Column = value
-				}
-				if orderby.String() != "" {
-					orderbyMap[name] = orderby
-					orderbyKeys = append(orderbyKeys, name)
-					break
-				}
-			}
-		case col, opr, val, aor:
-			for i, prefix := range prefixes {
-				if ref == nil {
-					ref = &PredGrp{}
-				}
-				if ref.Preds == nil {
-					ref.Preds = make(map[string]*Pred)
-				}
-				if ref.Preds[prefix] == nil {
-					ref.Preds[prefix] = &Pred{}
-				}
-				if i == len(prefixes)-1 {
-					switch suffix {
-					case col:
-						ref.Preds[prefix].Column = value
-					case opr:
-						ref.Preds[prefix].Operator = value
-					case val:
-						ref.Preds[prefix].Value = value
-						ref.Preds[prefix].Values = values
-					case aor:
-						if ref.Preds[prefix].PredGrp == nil {
-							ref.Preds[prefix].PredGrp = &PredGrp{}
-						}
-						ref.Preds[prefix].PredGrp.Or = value == Or
-					}
-					break
-				}
-				if ref.Preds[prefix].PredGrp == nil {
-					ref.Preds[prefix].PredGrp = &PredGrp{}
-				}
-				ref.Preds[prefix].Nested = true
-				ref = ref.Preds[prefix].PredGrp
-			}
+	return DefaultParser.ParseSelect(params)
+}
+
+// Clone returns a deep copy of sq. Rendering a SelectQuery never modifies it,
+// so a parsed query may be shared between goroutines as long as nobody modifies
+// it, and Clone gives each of them a copy they can modify.
+func (sq SelectQuery) Clone() SelectQuery {
+	sq.Select = append([]string(nil), sq.Select...)
+	sq.Where = sq.Where.Clone()
+	sq.OrderBys = append([]OrderBy(nil), sq.OrderBys...)
+	if sq.Scope != nil {
+		scope := make(Scope, len(sq.Scope))
+		for i, pred := range sq.Scope {
+			scope[i] = *pred.Clone()
 		}
+		sq.Scope = scope
+	}
+	return sq
+}
+
+// Clone returns a deep copy of grp
+func (grp *PredGrp) Clone() *PredGrp {
+	if grp == nil {
+		return nil
 	}
-	sort.Strings(orderbyKeys)
-	for _, key := range orderbyKeys {
-		orderby := orderbyMap[key]
-		if orderby.String() != "" {
-			query.OrderBys = append(query.OrderBys, orderby)
+	clone := &PredGrp{Or: grp.Or}
+	if grp.Preds != nil {
+		clone.Preds = make(map[string]*Pred, len(grp.Preds))
+		for key, pred := range grp.Preds {
+			clone.Preds[key] = pred.Clone()
 		}
 	}
-	return query
+	return clone
+}
+
+// Clone returns a deep copy of pred
+func (pred *Pred) Clone() *Pred {
+	if pred == nil {
+		return nil
+	}
+	clone := *pred
+	clone.Values = append([]string(nil), pred.Values...)
+	clone.PredGrp = pred.PredGrp.Clone()
+	return &clone
 }
 
 type SelectOption func(SelectQuery) SelectQuery
@@ -274,11 +252,32 @@ var SelectCount SelectOption = func(sq SelectQuery) SelectQuery {
 	return sq
 }
 
+// SelectWindowCount adds WindowCount to the selected columns
+var SelectWindowCount SelectOption = func(sq SelectQuery) SelectQuery {
+	sq.Select = append(sq.Select[:len(sq.Select):len(sq.Select)], WindowCount)
+	return sq
+}
+
 var SelectAll SelectOption = func(sq SelectQuery) SelectQuery {
 	sq.Select = []string{"*"}
 	return sq
 }
 
+func WithDialect(dialect Dialect) SelectOption {
+	return func(sq SelectQuery) SelectQuery {
+		sq.Dialect = dialect
+		return sq
+	}
+}
+
+// WithScope adds scope to the scope of the query
+func WithScope(scope Scope) SelectOption {
+	return func(sq SelectQuery) SelectQuery {
+		sq.Scope = append(append(Scope{}, sq.Scope...), scope...)
+		return sq
+	}
+}
+
 var WhereOnly SelectOption = func(sq SelectQuery) SelectQuery {
 	sq.Select = nil
 	sq.From = ""
@@ -289,56 +288,108 @@ var WhereOnly SelectOption = func(sq SelectQuery) SelectQuery {
 }
 
 func (sq SelectQuery) Sql(options ...SelectOption) (query string, args []interface{}) {
+	return sq.render(false, options)
+}
+
+// Pretty is like Sql, but puts each clause on its own line and indents the
+// nested predicate groups
+func (sq SelectQuery) Pretty(options ...SelectOption) (query string, args []interface{}) {
+	return sq.render(true, options)
+}
+
+// Just a helper variable for Pretty
+const indent = "    "
+
+func (sq SelectQuery) render(pretty bool, options []SelectOption) (query string, args []interface{}) {
+	// Options get their own copy, so that they can't modify the caller's query
+	sq = sq.Clone()
 	for _, option := range options {
 		sq = option(sq)
 	}
-	var selectStr, whereStr, orderByStr string
-	selectStr = strings.Join(dedup(removeEmptyStrings(sq.Select)), ","+space)
-	whereStr, args = stringifyWhere(sq.Where)
-	orderByStr = stringifyOrder(sq.OrderBys)
+	d := sq.dialect()
+	// In pretty mode a clause keyword goes on its own line and the clause body
+	// is indented on the line below it
+	clauseSep, bodySep, whereIndent, orderBySep := space, space, "", ","+space
+	if pretty {
+		clauseSep, bodySep, whereIndent, orderBySep = "\n", "\n"+indent, indent, "\n"+indent+","
+	}
+	var selectStr, whereStr, orderByStr, limitOffsetStr string
+	var selects []string
+	for _, column := range dedup(removeEmptyStrings(sq.Select)) {
+		selects = append(selects, ident(d, column))
+	}
+	selectStr = strings.Join(selects, ","+space)
+	whereStr, args = stringifyWhere(sq.scopedWhere(), d, whereIndent)
+	orderByStr = stringifyOrder(sq.OrderBys, d, orderBySep)
+	limitOffsetStr = d.LimitOffset(sq.Limit, sq.Offset, orderByStr != "")
 	buf := &strings.Builder{}
 	if selectStr != "" {
 		if buf.Len() > 0 {
-			buf.WriteString(space)
+			buf.WriteString(clauseSep)
 		}
-		buf.WriteString("SELECT" + space + selectStr)
+		buf.WriteString("SELECT" + bodySep + selectStr)
 	}
 	if sq.From != "" {
 		if buf.Len() > 0 {
-			buf.WriteString(space)
+			buf.WriteString(clauseSep)
 		}
-		buf.WriteString("FROM" + space + sq.From)
+		buf.WriteString("FROM" + bodySep + ident(d, sq.From))
 	}
 	if whereStr != "" {
 		if buf.Len() > 0 {
-			buf.WriteString(space)
+			buf.WriteString(clauseSep)
 		}
-		buf.WriteString("WHERE" + space + whereStr)
+		buf.WriteString("WHERE" + bodySep + whereStr)
 	}
 	if orderByStr != "" {
 		if buf.Len() > 0 {
-			buf.WriteString(space)
+			buf.WriteString(clauseSep)
 		}
-		buf.WriteString("ORDER BY" + space + orderByStr)
+		buf.WriteString("ORDER BY" + bodySep + orderByStr)
 	}
-	if sq.Limit != 0 {
+	if limitOffsetStr != "" {
 		if buf.Len() > 0 {
-			buf.WriteString(space)
+			buf.WriteString(clauseSep)
 		}
-		buf.WriteString("LIMIT" + space + strconv.Itoa(sq.Limit))
-	}
-	if sq.Offset != 0 {
-		if buf.Len() > 0 {
-			buf.WriteString(space)
-		}
-		buf.WriteString("OFFSET" + space + strconv.Itoa(sq.Offset))
+		buf.WriteString(limitOffsetStr)
 	}
 	query = buf.String()
-	query = ReplacePlaceholders(query)
+	query = replacePlaceholders(query, d.Placeholder)
 	return query, args
 }
 
-func stringifyWhere(where *PredGrp) (whereStr string, args []interface{}) {
+// scopedWhere returns sq.Where ANDed with sq.Scope
+func (sq SelectQuery) scopedWhere() *PredGrp {
+	if len(sq.Scope) == 0 {
+		return sq.Where
+	}
+	where := &PredGrp{Preds: make(map[string]*Pred)}
+	for i := range sq.Scope {
+		pred := sq.Scope[i]
+		if malformed(&pred) != "" {
+			pred = Pred{Operator: alwaysFalse}
+		}
+		where.Preds[strconv.Itoa(i+1)] = &pred
+	}
+	// Non-numeric keys sort after the numeric ones, so the scope comes first
+	where.Preds["where"] = &Pred{Nested: true, PredGrp: sq.Where}
+	return where
+}
+
+// alwaysFalse is the operator of a predicate that matches no rows, which stands
+// in for a malformed scope predicate
+const alwaysFalse = "getql:false"
+
+func (sq SelectQuery) dialect() Dialect {
+	if sq.Dialect == nil {
+		return Postgres
+	}
+	return sq.Dialect
+}
+
+// stringifyWhere joins the predicates of where with AND/OR. If lineIndent is
+// not empty, each predicate goes on its own line indented by lineIndent.
+func stringifyWhere(where *PredGrp, d Dialect, lineIndent string) (whereStr string, args []interface{}) {
 	if where == nil {
 		return whereStr, args
 	}
@@ -347,11 +398,15 @@ func stringifyWhere(where *PredGrp) (whereStr string, args []interface{}) {
 	if where.Or {
 		conjuctor = Or
 	}
-	for _, pred := range where.Preds {
-		predStr, argsTemp := stringifyPred(pred)
+	predSep := space + conjuctor + space
+	if lineIndent != "" {
+		predSep = "\n" + lineIndent + conjuctor + space
+	}
+	for _, key := range sortedKeys(where.Preds) {
+		predStr, argsTemp := stringifyPred(where.Preds[key], d, lineIndent)
 		if predStr != "" {
 			if buf.Len() > 0 {
-				buf.WriteString(space + conjuctor + space)
+				buf.WriteString(predSep)
 			}
 			buf.WriteString(predStr)
 			args = append(args, argsTemp...)
@@ -361,69 +416,83 @@ func stringifyWhere(where *PredGrp) (whereStr string, args []interface{}) {
 	return whereStr, args
 }
 
-func stringifyPred(pred *Pred) (predStr string, args []interface{}) {
+func stringifyPred(pred *Pred, d Dialect, lineIndent string) (predStr string, args []interface{}) {
 	if pred == nil {
 		return predStr, args
 	}
 	if pred.Nested {
-		whereStr, argsTemp := stringifyWhere(pred.PredGrp)
+		if lineIndent == "" {
+			whereStr, argsTemp := stringifyWhere(pred.PredGrp, d, "")
+			if whereStr == "" {
+				return predStr, args
+			}
+			args = append(args, argsTemp...)
+			return "(" + whereStr + ")", args
+		}
+		whereStr, argsTemp := stringifyWhere(pred.PredGrp, d, lineIndent+indent)
+		if whereStr == "" {
+			return predStr, args
+		}
 		args = append(args, argsTemp...)
-		return "(" + whereStr + ")", args
+		return "(\n" + lineIndent + indent + whereStr + "\n" + lineIndent + ")", args
+	}
+	operator := strings.TrimSpace(pred.Operator)
+	values := dedup(pred.Values)
+	if operator == alwaysFalse {
+		return "1 = 0", args
 	}
-	// Because we are going to be using ? as placeholders, we need to escape any existing ? into ??
-	pred.Column = strings.ReplaceAll(pred.Column, "?", "??")
-	pred.Operator = strings.TrimSpace(pred.Operator)
-	pred.Values = dedup(pred.Values)
 	if pred.Column == "" {
 		return predStr, args
 	}
-	switch pred.Operator {
+	// Because we are going to be using ? as placeholders, ident escapes any existing ? into ??
+	column := ident(d, pred.Column)
+	switch operator {
 	case Eq:
-		return fmt.Sprintf("%s = ?", pred.Column), []interface{}{pred.Value}
+		return fmt.Sprintf("%s = ?", column), []interface{}{pred.Value}
 	case Ne:
-		return fmt.Sprintf("%s <> ?", pred.Column), []interface{}{pred.Value}
+		return fmt.Sprintf("%s <> ?", column), []interface{}{pred.Value}
 	case In:
 		var placeholders []string
-		for _, val := range pred.Values {
+		for _, val := range values {
 			placeholders = append(placeholders, "?")
 			args = append(args, val)
 		}
-		return fmt.Sprintf("%s IN (%s)", pred.Column, strings.Join(placeholders, ","+space)), args
+		return fmt.Sprintf("%s IN (%s)", column, strings.Join(placeholders, ","+space)), args
 	case Gt:
-		return fmt.Sprintf("%s > ?", pred.Column), []interface{}{pred.Value}
+		return fmt.Sprintf("%s > ?", column), []interface{}{pred.Value}
 	case Ge:
-		return fmt.Sprintf("%s >= ?", pred.Column), []interface{}{pred.Value}
+		return fmt.Sprintf("%s >= ?", column), []interface{}{pred.Value}
 	case Lt:
-		return fmt.Sprintf("%s < ?", pred.Column), []interface{}{pred.Value}
+		return fmt.Sprintf("%s < ?", column), []interface{}{pred.Value}
 	case Le:
-		return fmt.Sprintf("%s <= ?", pred.Column), []interface{}{pred.Value}
+		return fmt.Sprintf("%s <= ?", column), []interface{}{pred.Value}
 	case Null:
-		return fmt.Sprintf("%s IS NULL", pred.Column), []interface{}{}
+		return fmt.Sprintf("%s IS NULL", column), []interface{}{}
 	case NotNull:
-		return fmt.Sprintf("%s IS NOT NULL", pred.Column), []interface{}{}
+		return fmt.Sprintf("%s IS NOT NULL", column), []interface{}{}
 	case Like:
-		return fmt.Sprintf("%s LIKE ?", pred.Column), []interface{}{pred.Value}
+		return fmt.Sprintf("%s LIKE ?", column), []interface{}{pred.Value}
 	case ILike:
-		return fmt.Sprintf("%s ILIKE ?", pred.Column), []interface{}{pred.Value}
+		return d.ILike(column), []interface{}{pred.Value}
 	case Between:
-		if len(pred.Values) < 2 {
+		if len(values) < 2 {
 			return "", []interface{}{}
 		}
-		smaller, greater := pred.Values[0], pred.Values[1]
-		return fmt.Sprintf("%s BETWEEN ? AND ?", pred.Column), []interface{}{smaller, greater}
+		smaller, greater := values[0], values[1]
+		return fmt.Sprintf("%s BETWEEN ? AND ?", column), []interface{}{smaller, greater}
 	default:
 		return predStr, args
 	}
 }
 
-func stringifyOrder(orderBys []OrderBy) (order string) {
+func stringifyOrder(orderBys []OrderBy, d Dialect, sep string) (order string) {
 	buf := &strings.Builder{}
 	for _, o := range orderBys {
 		if o.String() != "" {
 			if buf.Len() > 0 {
-				buf.WriteString(", ")
+				buf.WriteString(sep)
 			}
-			buf.WriteString(o.String())
+			buf.WriteString(d.OrderBy(ident(d, o.Column), o.Order, o.Nulls))
 		}
 	}
 	order = buf.String()
@@ -431,43 +500,55 @@ func stringifyOrder(orderBys []OrderBy) (order string) {
 }
 
 func Subst(query string, args ...interface{}) string {
+	return SubstDialect(Postgres, query, args...)
+}
+
+// SubstDialect is like Subst, but for queries using the placeholders of d
+func SubstDialect(d Dialect, query string, args ...interface{}) string {
 	query = regexp.MustCompile(`(?m)--.*$`).ReplaceAllString(query, " ") // Remove comments
 	query = regexp.MustCompile(`\\n|\\t`).ReplaceAllString(query, " ")   // Remove newlines/tabs
 	query = regexp.MustCompile(`\s+`).ReplaceAllString(query, " ")       // Replace multiple spaces with one space
 	query = strings.TrimSpace(query)
-	for i, arg := range args {
-		var val string
-		switch v := arg.(type) {
-		case string:
-			val = fmt.Sprintf("'%s'", v)
-		case int64:
-			val = strconv.FormatInt(v, 10)
-		case int32:
-			val = strconv.FormatInt(int64(v), 10)
-		case int:
-			val = strconv.Itoa(v)
-		case time.Time:
-			val = fmt.Sprintf("'%s'", v.Format(time.RFC3339))
-		case nil:
-			val = "NULL"
-		default:
-			// Try to unmarshal arg into a json string. If that fails, then give up and return
-			b, err := json.Marshal(arg)
-			if err != nil {
-				return query + space + err.Error()
-			}
-			val = fmt.Sprintf("'%s'", string(b))
-		}
-		query = strings.ReplaceAll(query, "$"+strconv.Itoa(i+1), val)
+	substituted, err := substitute(d, query, args)
+	if err != nil {
+		return query + space + err.Error()
 	}
+	query = substituted
 	if !strings.HasSuffix(query, ";") {
 		query = query + ";"
 	}
 	return query
 }
 
+// SubstPretty is like Subst, but keeps the layout of a query from
+// SelectQuery.Pretty
+func SubstPretty(query string, args ...interface{}) string {
+	return SubstPrettyDialect(Postgres, query, args...)
+}
+
+// SubstPrettyDialect is like SubstPretty, but for queries using the
+// placeholders of d
+func SubstPrettyDialect(d Dialect, query string, args ...interface{}) string {
+	query = strings.TrimSpace(query)
+	substituted, err := substitute(d, query, args)
+	if err != nil {
+		return query + "\n" + err.Error()
+	}
+	query = substituted
+	if !strings.HasSuffix(query, ";") {
+		query = query + "\n;"
+	}
+	return query
+}
+
 // Replace all ? with postgres placeholders $<number>
 func ReplacePlaceholders(query string) string {
+	return replacePlaceholders(query, Postgres.Placeholder)
+}
+
+// Replace all ? with the placeholder returned by placeholder, and unescape ??
+// into ?
+func replacePlaceholders(query string, placeholder func(int) string) string {
 	buf := &bytes.Buffer{}
 	i := 0
 	for {
@@ -482,7 +563,7 @@ func ReplacePlaceholders(query string) string {
 		} else { // Replace ? -> $<number>
 			i++
 			buf.WriteString(query[:p])
-			buf.WriteString("$" + strconv.Itoa(i))
+			buf.WriteString(placeholder(i))
 			query = query[p+1:]
 		}
 	}
@@ -502,6 +583,29 @@ func dedup(values []string) (deduped []string) {
 	return deduped
 }
 
+// Return the keys of preds in order, comparing numeric keys by value so that
+// 10 comes after 9
+func sortedKeys(preds map[string]*Pred) []string {
+	keys := make([]string, 0, len(preds))
+	for key := range preds {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, errA := strconv.Atoi(keys[i])
+		b, errB := strconv.Atoi(keys[j])
+		switch {
+		case errA == nil && errB == nil:
+			return a < b
+		case errA == nil:
+			return true
+		case errB == nil:
+			return false
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
 // Return new slice with empty strings removed
 func removeEmptyStrings(values []string) (purged []string) {
 	for _, value := range values {
@@ -514,16 +618,34 @@ func removeEmptyStrings(values []string) (purged []string) {
 }
 
 type SelectStats struct {
-	Query      string
-	Total      int
-	Limit      int
-	Page       int
-	TotalPages int
+	Query        string
+	Total        int
+	TotalUnknown bool          // The total wasn't counted or the count timed out, Total and TotalPages are not set
+	TotalInexact bool          // Total is capped or estimated, see CountStrategy
+	Count        CountStrategy // How Total was counted
+	Limit        int
+	Page         int
+	TotalPages   int
+	HasPrev      bool
+	HasNext      bool
 }
 
 type SelectStatsConfig struct {
 	MinimumLimit int
 	QueryOptions []SelectOption
+	Dialect      Dialect
+	Pretty       bool // Pretty print SelectStats.Query
+	Parser       Parser
+	Scope        Scope
+	Schema       *Schema
+	Role         string
+	Cache        Cache
+	CountTTL     time.Duration // How long totals are cached, 0 to not cache them
+	PageTTL      time.Duration // How long pages of results are cached, 0 to not cache them
+	CountTimeout time.Duration // If the count takes longer, the total is left unknown
+	QueryTimeout time.Duration // Includes the time spent reading the rows
+	Count        CountStrategy
+	CountCap     int // The most rows counted by CountCapped
 }
 
 type SelectStatsOption func(SelectStatsConfig) SelectStatsConfig
@@ -535,23 +657,296 @@ func SelectStatsMinimumLimit(limit int) SelectStatsOption {
 	}
 }
 
+func SelectStatsDialect(dialect Dialect) SelectStatsOption {
+	return func(config SelectStatsConfig) SelectStatsConfig {
+		config.Dialect = dialect
+		return config
+	}
+}
+
+func SelectStatsParser(parser Parser) SelectStatsOption {
+	return func(config SelectStatsConfig) SelectStatsConfig {
+		config.Parser = parser
+		return config
+	}
+}
+
+func SelectStatsScope(scope Scope) SelectStatsOption {
+	return func(config SelectStatsConfig) SelectStatsConfig {
+		config.Scope = append(append(Scope{}, config.Scope...), scope...)
+		return config
+	}
+}
+
+// SelectStatsSchema authorizes the query against schema for role
+func SelectStatsSchema(schema Schema, role string) SelectStatsOption {
+	return func(config SelectStatsConfig) SelectStatsConfig {
+		config.Schema = &schema
+		config.Role = role
+		return config
+	}
+}
+
+// SelectStatsCache caches the totals for countTTL, and the pages of results
+// read by DBSelectMapsWithStats for pageTTL. Entries are keyed by the
+// fingerprint of the query, which includes the scope.
+func SelectStatsCache(cache Cache, countTTL, pageTTL time.Duration) SelectStatsOption {
+	return func(config SelectStatsConfig) SelectStatsConfig {
+		config.Cache = cache
+		config.CountTTL = countTTL
+		config.PageTTL = pageTTL
+		return config
+	}
+}
+
+// SelectStatsCountTimeout gives up on counting the total after timeout, and
+// returns the rows with SelectStats.TotalUnknown set instead
+func SelectStatsCountTimeout(timeout time.Duration) SelectStatsOption {
+	return func(config SelectStatsConfig) SelectStatsConfig {
+		config.CountTimeout = timeout
+		return config
+	}
+}
+
+// SelectStatsQueryTimeout cancels the query for the rows after timeout,
+// including the time the caller spends reading the rows
+func SelectStatsQueryTimeout(timeout time.Duration) SelectStatsOption {
+	return func(config SelectStatsConfig) SelectStatsConfig {
+		config.QueryTimeout = timeout
+		return config
+	}
+}
+
+var SelectStatsPretty SelectStatsOption = func(config SelectStatsConfig) SelectStatsConfig {
+	config.Pretty = true
+	return config
+}
+
 var SelectStatsQueryAll SelectStatsOption = func(config SelectStatsConfig) SelectStatsConfig {
 	config.QueryOptions = append(config.QueryOptions, SelectAll)
 	return config
 }
 
-func DBSelectWithStats(db *sqlx.DB, params map[string][]string, options ...SelectStatsOption) (rows *sqlx.Rows, stats SelectStats, err error) {
-	config := SelectStatsConfig{MinimumLimit: 5}
+func DBSelectWithStats(db Queryer, params map[string][]string, options ...SelectStatsOption) (rows *sqlx.Rows, stats SelectStats, err error) {
+	return DBSelectWithStatsContext(context.Background(), db, params, options...)
+}
+
+// DBSelectWithStatsContext is like DBSelectWithStats, but cancels the queries
+// when ctx is done
+func DBSelectWithStatsContext(ctx context.Context, db Queryer, params map[string][]string, options ...SelectStatsOption) (rows *sqlx.Rows, stats SelectStats, err error) {
+	config := newSelectStatsConfig(options)
+	if config.Count == CountWindow || config.Count == CountNone {
+		return rows, stats, errMaterialize
+	}
+	sq, stats, err := config.prepare(params)
+	if err != nil {
+		return rows, stats, err
+	}
+	err = config.count(ctx, db, sq, &stats)
+	if err != nil {
+		return rows, stats, err
+	}
+	config.describe(sq, &stats)
+	// rows
+	query, args := sq.Sql()
+	queryCtx, cancel := config.withTimeout(ctx, config.QueryTimeout)
+	rows, err = queryx(queryCtx, db, query, args...)
+	if err != nil {
+		cancel()
+		return rows, stats, err
+	}
+	// The caller reads the rows after we return, so queryCtx can only be
+	// released once the timeout is up
+	if config.QueryTimeout > 0 {
+		time.AfterFunc(config.QueryTimeout, cancel)
+	}
+	return rows, stats, err
+}
+
+// DBSelectMapsWithStats is like DBSelectWithStats, but reads every row of the
+// page into a map of column names to values. []byte values are converted to
+// strings. Unlike rows, the results can be cached, see SelectStatsCache.
+func DBSelectMapsWithStats(db Queryer, params map[string][]string, options ...SelectStatsOption) (results []map[string]interface{}, stats SelectStats, err error) {
+	return DBSelectMapsWithStatsContext(context.Background(), db, params, options...)
+}
+
+// DBSelectMapsWithStatsContext is like DBSelectMapsWithStats, but cancels the
+// queries when ctx is done
+func DBSelectMapsWithStatsContext(ctx context.Context, db Queryer, params map[string][]string, options ...SelectStatsOption) (results []map[string]interface{}, stats SelectStats, err error) {
+	config := newSelectStatsConfig(options)
+	sq, stats, err := config.prepare(params)
+	if err != nil {
+		return results, stats, err
+	}
+	config.describe(sq, &stats)
+	queryOptions, window, err := config.beforePage(ctx, db, sq, &stats)
+	if err != nil {
+		return results, stats, err
+	}
+	// results
+	key := config.pageKey(sq, stats)
+	var total int
+	var ok bool
+	if !window {
+		results, ok = config.cachedPage(key)
+	}
+	if !ok {
+		results, err = config.selectMaps(ctx, db, sq, queryOptions...)
+		if err != nil {
+			return results, stats, err
+		}
+		if window && len(results) > 0 {
+			total, err = strconv.Atoi(fmt.Sprint(results[0][WindowCountColumn]))
+			if err != nil {
+				return results, stats, fmt.Errorf("getql: reading %s: %v", WindowCountColumn, err)
+			}
+			for _, result := range results {
+				delete(result, WindowCountColumn)
+			}
+		}
+		config.cachePage(key, results)
+	}
+	keep, err := config.afterPage(ctx, db, sq, &stats, len(results), window, total)
+	return results[:keep:keep], stats, err
+}
+
+// cachedPage returns a copy of the page cached under key, so that callers may
+// modify it
+func (config SelectStatsConfig) cachedPage(key string) (results []map[string]interface{}, ok bool) {
+	if config.Cache == nil || config.PageTTL <= 0 {
+		return nil, false
+	}
+	cached, ok := config.Cache.Get(key)
+	if !ok {
+		return nil, false
+	}
+	return copyRows(cached.([]map[string]interface{})), true
+}
+
+// cachePage caches a copy of results under key, so that the caller may go on
+// modifying results
+func (config SelectStatsConfig) cachePage(key string, results []map[string]interface{}) {
+	if config.Cache != nil && config.PageTTL > 0 {
+		config.Cache.Set(key, copyRows(results), config.PageTTL)
+	}
+}
+
+// copyRows returns a copy of rows and of every row in it. The values read by
+// selectMaps are never references, so they needn't be copied.
+func copyRows(rows []map[string]interface{}) []map[string]interface{} {
+	copied := make([]map[string]interface{}, len(rows))
+	for i, row := range rows {
+		copied[i] = make(map[string]interface{}, len(row))
+		for column, value := range row {
+			copied[i][column] = value
+		}
+	}
+	return copied
+}
+
+// selectMaps reads every row of the page into a map of column names to values
+func (config SelectStatsConfig) selectMaps(ctx context.Context, db Queryer, sq SelectQuery, options ...SelectOption) (results []map[string]interface{}, err error) {
+	query, args := sq.Sql(options...)
+	queryCtx, cancel := config.withTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	rows, err := queryx(queryCtx, db, query, args...)
+	if err != nil {
+		return results, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		result := make(map[string]interface{})
+		err = rows.MapScan(result)
+		if err != nil {
+			return results, err
+		}
+		for column, value := range result {
+			if b, ok := value.([]byte); ok {
+				result[column] = string(b)
+			}
+		}
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
+
+// SelectPlan is the work DBSelectWithStats does before running any queries,
+// for running them with something other than database/sql
+type SelectPlan struct {
+	Query  SelectQuery // The query for the page of results
+	Stats  SelectStats // Everything except Total and TotalPages is filled in
+	config SelectStatsConfig
+}
+
+// NewSelectPlan parses params into the queries for the requested page
+func NewSelectPlan(params map[string][]string, options ...SelectStatsOption) (plan SelectPlan, err error) {
+	plan.config = newSelectStatsConfig(options)
+	plan.Query, plan.Stats, err = plan.config.prepare(params)
+	if err != nil {
+		return plan, err
+	}
+	plan.config.describe(plan.Query, &plan.Stats)
+	return plan, nil
+}
+
+// Config returns the config built from the plan's options
+func (plan SelectPlan) Config() SelectStatsConfig {
+	return plan.config
+}
+
+// CountSql returns the query for the total number of rows. CountEstimated and
+// CountWindow need database/sql, so they are counted with CountExact.
+func (plan SelectPlan) CountSql() (query string, args []interface{}) {
+	return plan.config.countSql(plan.Query, plan.strategy())
+}
+
+// CachedTotal returns the total from the config's cache, if it's there
+func (plan SelectPlan) CachedTotal() (total int, ok bool) {
+	return plan.config.cachedTotal(plan.Query, plan.strategy())
+}
+
+// SetTotal fills in Stats.Total and Stats.TotalPages from the result of
+// CountSql, and caches the total
+func (plan *SelectPlan) SetTotal(total int) {
+	plan.config.cacheTotal(plan.Query, plan.strategy(), total)
+	plan.config.setTotal(&plan.Stats, plan.strategy(), total)
+}
+
+func (plan SelectPlan) strategy() CountStrategy {
+	strategy := plan.config.countStrategy(plan.Query)
+	if strategy == CountEstimated || strategy == CountWindow {
+		return CountExact
+	}
+	return strategy
+}
+
+func newSelectStatsConfig(options []SelectStatsOption) SelectStatsConfig {
+	config := SelectStatsConfig{MinimumLimit: 5, Parser: DefaultParser}
 	for _, option := range options {
 		config = option(config)
 	}
-	sq := ParseSelect(params)
-	// stats.Total
-	query, args := sq.Sql(SelectCount)
-	err = db.QueryRowx(query, args...).Scan(&stats.Total)
+	return config
+}
+
+// prepare parses params into the query for the requested page, and fills in
+// stats.Limit and stats.Page
+func (config SelectStatsConfig) prepare(params map[string][]string) (sq SelectQuery, stats SelectStats, err error) {
+	sq, err = config.Parser.Parse(params)
 	if err != nil {
-		return rows, stats, err
+		return sq, stats, err
+	}
+	if config.Schema != nil {
+		sq, err = config.Schema.AuthorizeWith(config.Parser, sq, config.Role)
+		if err != nil {
+			return sq, stats, err
+		}
 	}
+	sq.Dialect = config.Dialect
+	err = config.Scope.Validate()
+	if err != nil {
+		return sq, stats, err
+	}
+	sq.Scope = config.Scope
 	// stats.Limit
 	if sq.Limit < config.MinimumLimit {
 		sq.Limit = config.MinimumLimit
@@ -559,61 +954,58 @@ func DBSelectWithStats(db *sqlx.DB, params map[string][]string, options ...Selec
 	stats.Limit = sq.Limit
 	// stats.Page
 	stats.Page = 1
-	if params[Page] != nil {
-		page, _ := strconv.Atoi(params[Page][0])
+	if pageKey := config.Parser.Key(config.Parser.Page); params[pageKey] != nil {
+		page, _ := strconv.Atoi(params[pageKey][0])
 		if page > stats.Page {
 			stats.Page = page
 		}
 	}
-	sq.Offset = stats.Limit * (stats.Page - 1)
-	// stats.TotalPages
-	stats.TotalPages = int(math.Ceil(float64(stats.Total) / float64(stats.Limit)))
-	// stats.Query
-	query, args = sq.Sql(config.QueryOptions...)
-	stats.Query = Subst(query, args...)
-	// rows
-	query, args = sq.Sql()
-	rows, err = db.Queryx(query, args...)
-	return rows, stats, err
+	stats.HasPrev = stats.Page > 1
+	sq.Offset = 0
+	if stats.Limit > 0 {
+		// Checked before multiplying, so that a huge page can't overflow
+		// into a small or negative offset
+		maxOffset := config.Parser.Limits.MaxOffset
+		if maxOffset <= 0 {
+			maxOffset = math.MaxInt
+		}
+		if stats.Page-1 > maxOffset/stats.Limit {
+			err = ValidationErrors{{Param: config.Parser.Key(config.Parser.Page), Message: "page too deep"}}
+			return sq, stats, err
+		}
+		sq.Offset = stats.Limit * (stats.Page - 1)
+	}
+	return sq, stats, nil
 }
 
-func ResolvePage(params map[string][]string) map[string][]string {
-	limit := 5
-	page := 1
-	if params[Lim] != nil {
-		l, _ := strconv.Atoi(params[Lim][0])
-		if l > limit {
-			limit = l
-		}
+// withTimeout returns ctx with timeout applied, if there is one
+func (config SelectStatsConfig) withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return ctx, func() {}
 	}
-	if params[Page] != nil {
-		p, _ := strconv.Atoi(params[Page][0])
-		if p > page {
-			page = p
-		}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// describe fills in stats.Query
+func (config SelectStatsConfig) describe(sq SelectQuery, stats *SelectStats) {
+	options := config.QueryOptions
+	if config.Schema != nil {
+		// Options like SelectAll must not widen the query past the schema
+		options = append(options[:len(options):len(options)], config.Schema.Restrict(config.Role))
+	}
+	if config.Pretty {
+		query, args := sq.Pretty(options...)
+		stats.Query = SubstPrettyDialect(sq.dialect(), query, args...)
+	} else {
+		query, args := sq.Sql(options...)
+		stats.Query = SubstDialect(sq.dialect(), query, args...)
 	}
-	offset := limit * (page - 1)
-	params[Off] = []string{strconv.Itoa(offset)}
-	return params
+}
+
+func ResolvePage(params map[string][]string) map[string][]string {
+	return DefaultParser.ResolvePage(params)
 }
 
 func PaginateHandlerFunc(url string, delta int, errorHandler func(http.ResponseWriter, *http.Request, error)) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		err := r.ParseForm()
-		if err != nil {
-			errorHandler(w, r, err)
-			return
-		}
-		params := ScrubForm(r.Form)
-		page, _ := strconv.Atoi(r.FormValue(Page))
-		if page != 0 {
-			page += delta
-			params[Page] = []string{strconv.Itoa(page)}
-		}
-		if page == 0 {
-			params[Page] = []string{"1"}
-		}
-		newUrl := ScrubUrl(url, params)
-		http.Redirect(w, r, newUrl, http.StatusMovedPermanently)
-	}
+	return DefaultParser.PaginateHandlerFunc(url, delta, errorHandler)
 }
@@ -1,8 +1,13 @@
 package getql
 
 import (
+	"context"
 	"fmt"
 	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
 )
 
 func TestParseSelect(t *testing.T) {
@@ -46,3 +51,347 @@ func TestParseSelect(t *testing.T) {
 	fmt.Println(sq.Sql(SelectCount))
 	fmt.Println(sq.Sql(WhereOnly))
 }
+
+func TestPretty(t *testing.T) {
+	params := map[string][]string{
+		Sel:           []string{"a", "b", "c"},
+		Frm:           []string{"some_table"},
+		Col("1"):      []string{"fruit"},
+		Opr("1"):      []string{Eq},
+		Val("1"):      []string{"apple"},
+		Col("2"):      []string{"color"},
+		Opr("2"):      []string{In},
+		Val("2"):      []string{"red", "green"},
+		Aor("3"):      []string{Or},
+		Col("3", "1"): []string{"user"},
+		Opr("3", "1"): []string{Eq},
+		Val("3", "1"): []string{"john"},
+		Col("3", "2"): []string{"name"},
+		Opr("3", "2"): []string{Ne},
+		Val("3", "2"): []string{"sammy"},
+		Ord("1"):      []string{"id", Asc},
+		Ord("2"):      []string{"time", Desc},
+	}
+	query, args := ParseSelect(params).Pretty()
+	got := SubstPretty(query, args...)
+	want := `SELECT
+    "a", "b", "c"
+FROM
+    "some_table"
+WHERE
+    "fruit" = 'apple'
+    AND "color" IN ('red', 'green')
+    AND (
+        "user" = 'john'
+        OR "name" <> 'sammy'
+    )
+ORDER BY
+    "id" ASC
+    ,"time" DESC
+;`
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestScope(t *testing.T) {
+	params := map[string][]string{
+		Frm:      []string{"t"},
+		Col("1"): []string{"a"},
+		Opr("1"): []string{Eq},
+		Val("1"): []string{"x"},
+		Col("2"): []string{"b"},
+		Opr("2"): []string{Eq},
+		Val("2"): []string{"y"},
+	}
+	scope := Scope{
+		{Column: "tenant_id", Operator: Eq, Value: "42"},
+		{Column: "deleted_at", Operator: Null},
+	}
+	sq := ParseSelect(params)
+	sq.Where.Or = true
+	query, args := sq.Sql(WithScope(scope), SelectCount)
+	want := `SELECT COUNT(*) FROM "t" WHERE "tenant_id" = $1 AND "deleted_at" IS NULL AND ("a" = $2 OR "b" = $3)`
+	if query != want {
+		t.Errorf("got %q, want %q", query, want)
+	}
+	if len(args) != 3 || args[0] != "42" {
+		t.Errorf("got args %v", args)
+	}
+	query, _ = SelectQuery{From: "t"}.Sql(WithScope(scope))
+	want = `FROM "t" WHERE "tenant_id" = $1 AND "deleted_at" IS NULL`
+	if query != want {
+		t.Errorf("got %q, want %q", query, want)
+	}
+}
+
+func TestMalformedScope(t *testing.T) {
+	db := openTestDB(t)
+	params := map[string][]string{Frm: []string{"fruits"}}
+	tests := []struct {
+		name string
+		pred Pred
+	}{
+		{"symbol operator", Pred{Column: "tenant_id", Operator: "=", Value: "42"}},
+		{"unknown operator", Pred{Column: "tenant_id", Operator: "eq", Value: "42"}},
+		{"ignored operator", Pred{Column: "tenant_id", Operator: Ignore, Value: "42"}},
+		{"short between", Pred{Column: "price", Operator: Between, Values: []string{"10"}}},
+		{"empty in", Pred{Column: "tenant_id", Operator: In}},
+		{"no column", Pred{Operator: Eq, Value: "42"}},
+		{"empty group", Pred{Nested: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scope := Scope{tt.pred}
+			if err := scope.Validate(); err == nil {
+				t.Error("expected a validation error")
+			}
+			query, _ := SelectQuery{From: "t"}.Sql(WithScope(scope))
+			want := `FROM "t" WHERE 1 = 0`
+			if query != want {
+				t.Errorf("got %q, want %q", query, want)
+			}
+			_, _, err := DBSelectMapsWithStats(db, params, SelectStatsScope(scope))
+			if err == nil {
+				t.Error("expected an error selecting with a malformed scope")
+			}
+		})
+	}
+}
+
+func TestSqlDoesNotModifyQuery(t *testing.T) {
+	params := map[string][]string{
+		Frm:           []string{"t"},
+		Col("1"):      []string{"a?"},
+		Opr("1"):      []string{" " + In + " "},
+		Val("1"):      []string{"x", "y"},
+		Col("2", "1"): []string{"b"},
+		Opr("2", "1"): []string{Eq},
+		Val("2", "1"): []string{"z"},
+	}
+	sq := ParseSelect(params)
+	clone := sq.Clone()
+	first, _ := sq.Sql()
+	second, _ := sq.Sql(SelectCount)
+	third, _ := sq.Sql()
+	if first != third {
+		t.Errorf("got %q then %q", first, third)
+	}
+	if want := `FROM "t" WHERE "a?" IN ($1, $2) AND ("b" = $3)`; first != want {
+		t.Errorf("got %q, want %q", first, want)
+	}
+	if second == first {
+		t.Errorf("SelectCount had no effect")
+	}
+	clone.Where.Preds["2"].PredGrp.Preds["1"].Column = "c"
+	if sq.Where.Preds["2"].PredGrp.Preds["1"].Column != "b" {
+		t.Errorf("Clone shares the predicate tree")
+	}
+	done := make(chan string)
+	for i := 0; i < 4; i++ {
+		go func() {
+			query, _ := sq.Sql(WithScope(Scope{{Column: "tenant_id", Operator: Eq, Value: "1"}}))
+			done <- query
+		}()
+	}
+	for i := 0; i < 4; i++ {
+		<-done
+	}
+}
+
+// openTestDB returns an in-memory SQLite database with a fruits table of 12
+// rows
+func openTestDB(t *testing.T) *sqlx.DB {
+	db, err := sqlx.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every connection to :memory: is a different database
+	db.SetMaxOpenConns(1)
+	db.MustExec(`CREATE TABLE fruits (id INTEGER PRIMARY KEY, name TEXT, color TEXT, price INTEGER)`)
+	colors := []string{"red", "green", "yellow"}
+	for i := 1; i <= 12; i++ {
+		db.MustExec(`INSERT INTO fruits (id, name, color, price) VALUES (?, ?, ?, ?)`, i, fmt.Sprintf("fruit%d", i), colors[i%3], i*10)
+	}
+	return db
+}
+
+func TestDBSelectWithStats(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+	params := map[string][]string{
+		Sel:      []string{"id", "name"},
+		Frm:      []string{"fruits"},
+		Col("1"): []string{"color"},
+		Opr("1"): []string{In},
+		Val("1"): []string{"red", "green"},
+		Ord("1"): []string{"id", Asc},
+		Page:     []string{"2"},
+	}
+	rows, stats, err := DBSelectWithStats(db, params, SelectStatsDialect(SQLite))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var ids []int
+	for rows.Next() {
+		var id int
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	if stats.Total != 8 || stats.TotalPages != 2 || stats.Page != 2 || stats.Limit != 5 {
+		t.Errorf("got stats %+v", stats)
+	}
+	if fmt.Sprint(ids) != "[9 10 12]" {
+		t.Errorf("got ids %v", ids)
+	}
+	want := `SELECT "id", "name" FROM "fruits" WHERE "color" IN ('red', 'green') ORDER BY "id" ASC LIMIT 5 OFFSET 5;`
+	if stats.Query != want {
+		t.Errorf("got query %q, want %q", stats.Query, want)
+	}
+}
+
+func TestPageTooDeep(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+	limited := DefaultParser
+	limited.Limits.MaxOffset = 1000
+	for _, p := range []Parser{DefaultParser, limited} {
+		params := map[string][]string{
+			Frm:  []string{"fruits"},
+			Lim:  []string{"5"},
+			Page: []string{"3689348814741910324"},
+		}
+		_, _, err := DBSelectMapsWithStats(db, params, SelectStatsDialect(SQLite), SelectStatsParser(p))
+		if errs, ok := err.(ValidationErrors); !ok || len(errs) != 1 || errs[0].Param != Page {
+			t.Errorf("got %v, want a ValidationError for %s", err, Page)
+		}
+	}
+	params := map[string][]string{Sel: []string{"id"}, Frm: []string{"fruits"}, Lim: []string{"5"}, Page: []string{"201"}}
+	_, stats, err := DBSelectMapsWithStats(db, params, SelectStatsDialect(SQLite), SelectStatsParser(limited))
+	if err != nil || stats.Page != 201 {
+		t.Errorf("got page %d and error %v, want page 201 at offset 1000", stats.Page, err)
+	}
+}
+
+func TestDBSelectWithStatsContext(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+	params := map[string][]string{
+		Sel:      []string{"id"},
+		Frm:      []string{"fruits"},
+		Ord("1"): []string{"id", Asc},
+	}
+	// A count that times out leaves the total unknown but still returns rows
+	results, stats, err := DBSelectMapsWithStatsContext(context.Background(), db, params,
+		SelectStatsDialect(SQLite), SelectStatsCountTimeout(time.Nanosecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !stats.TotalUnknown || stats.Total != 0 || stats.TotalPages != 0 {
+		t.Errorf("got stats %+v", stats)
+	}
+	if len(results) != 5 || !stats.HasNext {
+		t.Errorf("got %d results and HasNext %v, want 5 and a next page", len(results), stats.HasNext)
+	}
+	lastPage := map[string][]string{Page: []string{"3"}}
+	for key, values := range params {
+		lastPage[key] = values
+	}
+	results, stats, err = DBSelectMapsWithStatsContext(context.Background(), db, lastPage,
+		SelectStatsDialect(SQLite), SelectStatsCountTimeout(time.Nanosecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || stats.HasNext || !stats.HasPrev {
+		t.Errorf("got %d results and stats %+v, want 2 on the last page", len(results), stats)
+	}
+	// A cancelled context is an error
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, _, err = DBSelectWithStatsContext(ctx, db, params, SelectStatsDialect(SQLite))
+	if err != context.Canceled {
+		t.Errorf("got err %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestSelectPlan(t *testing.T) {
+	params := map[string][]string{
+		Sel:      []string{"id"},
+		Frm:      []string{"fruits"},
+		Col("1"): []string{"color"},
+		Opr("1"): []string{Eq},
+		Val("1"): []string{"red"},
+		Page:     []string{"3"},
+	}
+	cache := NewLRUCache(8)
+	plan, err := NewSelectPlan(params, SelectStatsCache(cache, time.Minute, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	query, args := plan.CountSql()
+	want := `SELECT COUNT(*) FROM "fruits" WHERE "color" = $1`
+	if query != want || fmt.Sprint(args) != "[red]" {
+		t.Errorf("got count %q %v, want %q [red]", query, args, want)
+	}
+	if plan.Stats.Limit != 5 || plan.Stats.Page != 3 || plan.Query.Offset != 10 {
+		t.Errorf("got stats %+v, offset %d", plan.Stats, plan.Query.Offset)
+	}
+	if _, ok := plan.CachedTotal(); ok {
+		t.Error("total cached before it was set")
+	}
+	plan.SetTotal(11)
+	if plan.Stats.Total != 11 || plan.Stats.TotalPages != 3 {
+		t.Errorf("got stats %+v", plan.Stats)
+	}
+	plan, _ = NewSelectPlan(params, SelectStatsCache(cache, time.Minute, 0))
+	if total, ok := plan.CachedTotal(); !ok || total != 11 {
+		t.Errorf("got cached total %d %v, want 11 true", total, ok)
+	}
+}
+
+func TestWindowCount(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+	params := map[string][]string{
+		Sel:      []string{"id"},
+		Frm:      []string{"fruits"},
+		Col("1"): []string{"color"},
+		Opr("1"): []string{In},
+		Val("1"): []string{"red", "green"},
+		Ord("1"): []string{"id", Asc},
+	}
+	tests := []struct {
+		page    string
+		values  []string
+		total   int
+		results int
+	}{
+		{"2", []string{"red", "green"}, 8, 3},
+		{"3", []string{"red", "green"}, 8, 0}, // past the end, counted separately
+		{"1", []string{"blue"}, 0, 0},
+	}
+	for _, tt := range tests {
+		params[Page] = []string{tt.page}
+		params[Val("1")] = tt.values
+		results, stats, err := DBSelectMapsWithStats(db, params, SelectStatsDialect(SQLite), SelectStatsWindowCount)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stats.Total != tt.total || len(results) != tt.results {
+			t.Errorf("page %s: got total %d, %d results, want %d, %d", tt.page, stats.Total, len(results), tt.total, tt.results)
+		}
+		for _, result := range results {
+			if _, ok := result[WindowCountColumn]; ok {
+				t.Errorf("page %s: %s left in %v", tt.page, WindowCountColumn, result)
+			}
+		}
+	}
+	_, _, err := DBSelectWithStats(db, params, SelectStatsDialect(SQLite), SelectStatsWindowCount)
+	if err == nil {
+		t.Error("DBSelectWithStats accepted SelectStatsWindowCount")
+	}
+}
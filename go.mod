@@ -1,9 +1,14 @@
 module github.com/bokwoon95/getql
 
-go 1.13
+go 1.18
 
 require (
 	github.com/jmoiron/sqlx v1.2.0
-	golang.org/x/net v0.0.0-20200222125558-5a598a2470a0
+	github.com/mattn/go-sqlite3 v1.14.6
+	golang.org/x/net v0.6.0
+)
+
+require (
+	github.com/lib/pq v1.10.2 // indirect
 	google.golang.org/appengine v1.6.5 // indirect
 )
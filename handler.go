@@ -0,0 +1,170 @@
+package getql
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+type JSONHandlerConfig struct {
+	StatsOptions []SelectStatsOption
+	Role         func(*http.Request) string // The role the schema is authorized for
+	Scope        func(*http.Request) Scope  // Added to the scope of every query
+	ErrorHandler ErrorHandler               // Passed the errors behind a 500
+	// Transform is applied to a copy of every row before it is written, and
+	// may modify the copy in place
+	Transform func(r *http.Request, row map[string]interface{}) map[string]interface{}
+}
+
+// ErrorHandler is passed the errors that JSONHandler and CSVHandler don't
+// describe to the client, such as the ones behind a 500, so that they can be
+// logged
+type ErrorHandler func(r *http.Request, err error)
+
+type JSONHandlerOption func(JSONHandlerConfig) JSONHandlerConfig
+
+// JSONHandlerStats passes options on to DBSelectMapsWithStats
+func JSONHandlerStats(options ...SelectStatsOption) JSONHandlerOption {
+	return func(config JSONHandlerConfig) JSONHandlerConfig {
+		config.StatsOptions = append(config.StatsOptions[:len(config.StatsOptions):len(config.StatsOptions)], options...)
+		return config
+	}
+}
+
+func JSONHandlerRole(role func(*http.Request) string) JSONHandlerOption {
+	return func(config JSONHandlerConfig) JSONHandlerConfig {
+		config.Role = role
+		return config
+	}
+}
+
+func JSONHandlerScope(scope func(*http.Request) Scope) JSONHandlerOption {
+	return func(config JSONHandlerConfig) JSONHandlerConfig {
+		config.Scope = scope
+		return config
+	}
+}
+
+func JSONHandlerTransform(transform func(r *http.Request, row map[string]interface{}) map[string]interface{}) JSONHandlerOption {
+	return func(config JSONHandlerConfig) JSONHandlerConfig {
+		config.Transform = transform
+		return config
+	}
+}
+
+func JSONHandlerErrorHandler(errorHandler ErrorHandler) JSONHandlerOption {
+	return func(config JSONHandlerConfig) JSONHandlerConfig {
+		config.ErrorHandler = errorHandler
+		return config
+	}
+}
+
+// JSONResponse is what JSONHandler writes on success
+type JSONResponse struct {
+	Data  []map[string]interface{} `json:"data"`
+	Meta  JSONMeta                 `json:"meta"`
+	Links JSONLinks                `json:"links"`
+}
+
+type JSONMeta struct {
+	Total        *int `json:"total"` // null if the total is unknown
+	TotalInexact bool `json:"totalInexact,omitempty"`
+	Page         int  `json:"page"`
+	Limit        int  `json:"limit"`
+	TotalPages   *int `json:"totalPages"` // null if the total is unknown
+}
+
+type JSONLinks struct {
+	Self string `json:"self"`
+	Next string `json:"next,omitempty"`
+	Prev string `json:"prev,omitempty"`
+}
+
+// JSONErrorResponse is what JSONHandler writes on failure. Only
+// ValidationErrors, which come with a 400, are described in detail.
+type JSONErrorResponse struct {
+	Errors ValidationErrors `json:"errors"`
+}
+
+// JSONHandler serves the rows of schema's table that match the getql
+// parameters of the request as a JSONResponse. The links to the other pages
+// keep the request's getql parameters.
+func JSONHandler(db Queryer, schema Schema, options ...JSONHandlerOption) http.Handler {
+	var config JSONHandlerConfig
+	for _, option := range options {
+		config = option(config)
+	}
+	p := newSelectStatsConfig(config.StatsOptions).Parser
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := r.ParseForm()
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, JSONErrorResponse{ValidationErrors{{Message: err.Error()}}})
+			return
+		}
+		params := p.ScrubForm(cloneForm(r.Form))
+		var role string
+		if config.Role != nil {
+			role = config.Role(r)
+		}
+		statsOptions := append(config.StatsOptions[:len(config.StatsOptions):len(config.StatsOptions)], SelectStatsSchema(schema, role))
+		if config.Scope != nil {
+			statsOptions = append(statsOptions, SelectStatsScope(config.Scope(r)))
+		}
+		results, stats, err := DBSelectMapsWithStatsContext(r.Context(), db, params, statsOptions...)
+		if errs, ok := err.(ValidationErrors); ok {
+			writeJSON(w, http.StatusBadRequest, JSONErrorResponse{errs})
+			return
+		}
+		if err != nil {
+			if config.ErrorHandler != nil {
+				config.ErrorHandler(r, err)
+			}
+			status := http.StatusInternalServerError
+			writeJSON(w, status, JSONErrorResponse{ValidationErrors{{Message: http.StatusText(status)}}})
+			return
+		}
+		response := JSONResponse{Data: make([]map[string]interface{}, 0, len(results))}
+		if config.Transform != nil {
+			// The rows may be shared with the page cache
+			results = copyRows(results)
+		}
+		for _, result := range results {
+			if config.Transform != nil {
+				result = config.Transform(r, result)
+			}
+			response.Data = append(response.Data, result)
+		}
+		response.Meta = JSONMeta{TotalInexact: stats.TotalInexact, Page: stats.Page, Limit: stats.Limit}
+		if !stats.TotalUnknown {
+			response.Meta.Total, response.Meta.TotalPages = &stats.Total, &stats.TotalPages
+		}
+		pageURL := func(page int) string {
+			form := cloneForm(params)
+			form.Set(p.Key(p.Page), strconv.Itoa(page))
+			return p.ScrubUrl(r.URL.Path, form)
+		}
+		response.Links.Self = pageURL(stats.Page)
+		if stats.HasNext {
+			response.Links.Next = pageURL(stats.Page + 1)
+		}
+		if stats.HasPrev {
+			response.Links.Prev = pageURL(stats.Page - 1)
+		}
+		writeJSON(w, http.StatusOK, response)
+	})
+}
+
+func cloneForm(form url.Values) url.Values {
+	clone := make(url.Values, len(form))
+	for key, values := range form {
+		clone[key] = append([]string(nil), values...)
+	}
+	return clone
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
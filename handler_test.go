@@ -0,0 +1,120 @@
+package getql
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestJSONHandler(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+	schema := Schema{
+		Table: "fruits",
+		Columns: []Column{
+			{Name: "id", Perm: PermSelect | PermSort},
+			{Name: "name", Perm: PermSelect},
+			{Name: "color", Perm: PermAll},
+			{Name: "price", Perm: PermSelect},
+		},
+	}
+	handler := JSONHandler(db, schema,
+		JSONHandlerStats(SelectStatsDialect(SQLite), SelectStatsCache(NewLRUCache(10), time.Minute, time.Minute)),
+		JSONHandlerScope(func(r *http.Request) Scope {
+			return Scope{{Column: "id", Operator: Gt, Value: "1"}}
+		}),
+		JSONHandlerTransform(func(r *http.Request, row map[string]interface{}) map[string]interface{} {
+			row["label"] = row["name"]
+			delete(row, "name")
+			return row
+		}),
+	)
+	query := url.Values{
+		Sel:      []string{"id", "name"},
+		Col("1"): []string{"color"},
+		Opr("1"): []string{In},
+		Val("1"): []string{"red", "green"},
+		Ord("1"): []string{"id", Asc},
+		"other":  []string{"dropped"},
+	}
+	var w *httptest.ResponseRecorder
+	var response JSONResponse
+	// The second time round the page is cached, and must not have been
+	// modified by Transform
+	for i := 0; i < 2; i++ {
+		w = httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "/fruits?"+query.Encode(), nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("got status %d: %s", w.Code, w.Body)
+		}
+		response = JSONResponse{}
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatal(err)
+		}
+		if response.Data[0]["label"] != "fruit3" {
+			t.Errorf("got first row %v", response.Data[0])
+		}
+	}
+	// id 1 is green, but out of scope
+	if *response.Meta.Total != 7 || *response.Meta.TotalPages != 2 || response.Meta.Page != 1 || len(response.Data) != 5 {
+		t.Errorf("got meta %+v and %d rows", response.Meta, len(response.Data))
+	}
+	query.Del("other")
+	query.Set(Page, "2")
+	if next := "/fruits?" + query.Encode(); response.Links.Next != next || response.Links.Prev != "" {
+		t.Errorf("got links %+v, want next %s", response.Links, next)
+	}
+	// price may not be filtered by
+	query.Set(Col("1"), "price")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/fruits?"+query.Encode(), nil))
+	var errResponse JSONErrorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &errResponse); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusBadRequest || len(errResponse.Errors) != 1 {
+		t.Errorf("got status %d: %s", w.Code, w.Body)
+	}
+}
+
+func TestJSONHandlerError(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+	schema := Schema{Table: "missing", Columns: []Column{{Name: "id", Perm: PermAll}}}
+	var handled error
+	handler := JSONHandler(db, schema,
+		JSONHandlerStats(SelectStatsDialect(SQLite)),
+		JSONHandlerErrorHandler(func(r *http.Request, err error) {
+			handled = err
+		}),
+	)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/missing", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("got status %d: %s", w.Code, w.Body)
+	}
+	if handled == nil {
+		t.Errorf("the error handler wasn't passed the error")
+	}
+}
+
+func TestJSONHandlerUnknownTotal(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+	schema := Schema{Table: "fruits", Columns: []Column{{Name: "id", Perm: PermAll}}}
+	handler := JSONHandler(db, schema,
+		JSONHandlerStats(SelectStatsDialect(SQLite), SelectStatsCountTimeout(time.Nanosecond)),
+	)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/fruits", nil))
+	var response JSONResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Meta.Total != nil || len(response.Data) != 5 || response.Links.Next == "" {
+		t.Errorf("got meta %+v, %d rows and links %+v, want a next link", response.Meta, len(response.Data), response.Links)
+	}
+}
@@ -10,63 +10,74 @@ import (
 )
 
 func AddKeywords(funcs map[string]interface{}) map[string]interface{} {
-	funcs["GetqlSel"] = func() string { return Sel }
-	funcs["GetqlFrm"] = func() string { return Frm }
-	funcs["GetqlLim"] = func() string { return Lim }
-	funcs["GetqlPage"] = func() string { return Page }
-	funcs["GetqlCol"] = Col
-	funcs["GetqlOpr"] = Opr
-	funcs["GetqlVal"] = Val
-	funcs["GetqlOrd"] = Ord
-	funcs["GetqlAor"] = Aor
-	funcs["GetqlJoin"] = Join
-
-	funcs["GetqlEq"] = func() string { return Eq }
-	funcs["GetqlNe"] = func() string { return Ne }
-	funcs["GetqlIn"] = func() string { return In }
-	funcs["GetqlGt"] = func() string { return Gt }
-	funcs["GetqlGe"] = func() string { return Ge }
-	funcs["GetqlLt"] = func() string { return Lt }
-	funcs["GetqlLe"] = func() string { return Le }
-	funcs["GetqlNull"] = func() string { return Null }
-	funcs["GetqlNotNull"] = func() string { return NotNull }
-	funcs["GetqlLike"] = func() string { return Like }
-	funcs["GetqlILike"] = func() string { return ILike }
-	funcs["GetqlBetween"] = func() string { return Between }
-	funcs["GetqlIgnore"] = func() string { return Ignore }
-	funcs = AddOperatorKV(funcs)
-
-	funcs["GetqlAsc"] = func() string { return Asc }
-	funcs["GetqlDesc"] = func() string { return Desc }
+	return DefaultParser.AddKeywords(funcs)
+}
+
+func (p Parser) AddKeywords(funcs map[string]interface{}) map[string]interface{} {
+	funcs["GetqlSel"] = func() string { return p.Key(p.Suffixes.Sel) }
+	funcs["GetqlFrm"] = func() string { return p.Key(p.Suffixes.Frm) }
+	funcs["GetqlLim"] = func() string { return p.Key(p.Suffixes.Lim) }
+	funcs["GetqlPage"] = func() string { return p.Key(p.Page) }
+	funcs["GetqlCol"] = p.Col
+	funcs["GetqlOpr"] = p.Opr
+	funcs["GetqlVal"] = p.Val
+	funcs["GetqlOrd"] = p.Ord
+	funcs["GetqlAor"] = p.Aor
+	funcs["GetqlJoin"] = p.Join
+	funcs["GetqlNamespace"] = p.WithNamespace
+
+	funcs["GetqlEq"] = func() string { return p.Tokens.Eq }
+	funcs["GetqlNe"] = func() string { return p.Tokens.Ne }
+	funcs["GetqlIn"] = func() string { return p.Tokens.In }
+	funcs["GetqlGt"] = func() string { return p.Tokens.Gt }
+	funcs["GetqlGe"] = func() string { return p.Tokens.Ge }
+	funcs["GetqlLt"] = func() string { return p.Tokens.Lt }
+	funcs["GetqlLe"] = func() string { return p.Tokens.Le }
+	funcs["GetqlNull"] = func() string { return p.Tokens.Null }
+	funcs["GetqlNotNull"] = func() string { return p.Tokens.NotNull }
+	funcs["GetqlLike"] = func() string { return p.Tokens.Like }
+	funcs["GetqlILike"] = func() string { return p.Tokens.ILike }
+	funcs["GetqlBetween"] = func() string { return p.Tokens.Between }
+	funcs["GetqlIgnore"] = func() string { return p.Tokens.Ignore }
+	funcs = p.AddOperatorKV(funcs)
+
+	funcs["GetqlAsc"] = func() string { return p.Tokens.Asc }
+	funcs["GetqlDesc"] = func() string { return p.Tokens.Desc }
+	funcs["GetqlNullsFirst"] = func() string { return p.Tokens.NullsFirst }
+	funcs["GetqlNullsLast"] = func() string { return p.Tokens.NullsLast }
 	funcs["GetqlAscDescKV"] = func() []KV {
 		return []KV{
-			KV{Key: Asc, Value: "Ascending"},
-			KV{Key: Desc, Value: "Descending"},
-			KV{Key: Ignore, Value: "IGNORE"},
+			KV{Key: p.Tokens.Asc, Value: "Ascending"},
+			KV{Key: p.Tokens.Desc, Value: "Descending"},
+			KV{Key: p.Tokens.Ignore, Value: "IGNORE"},
 		}
 	}
 
-	funcs["GetqlAnd"] = func() string { return And }
-	funcs["GetqlOr"] = func() string { return Or }
+	funcs["GetqlAnd"] = func() string { return p.Tokens.And }
+	funcs["GetqlOr"] = func() string { return p.Tokens.Or }
 	funcs["GetqlAndOrKV"] = func() []KV {
 		return []KV{
-			KV{Key: And, Value: "AND"},
-			KV{Key: Or, Value: "OR"},
-			KV{Key: Ignore, Value: "IGNORE"},
+			KV{Key: p.Tokens.And, Value: "AND"},
+			KV{Key: p.Tokens.Or, Value: "OR"},
+			KV{Key: p.Tokens.Ignore, Value: "IGNORE"},
 		}
 	}
 	return funcs
 }
 
 func Funcs(funcs map[string]interface{}, params map[string][]string) map[string]interface{} {
-	funcs = AddKeywords(funcs)
-	funcs["GetqlFilter"] = func() string { return "getql-filter" }
-	funcs["GetqlFilterCheckbox"] = FilterCheckbox(params)
-	funcs["GetqlFilterCheckboxLabel"] = func() string { return "getql-filter-checkbox" }
+	return DefaultParser.Funcs(funcs, params)
+}
+
+func (p Parser) Funcs(funcs map[string]interface{}, params map[string][]string) map[string]interface{} {
+	funcs = p.AddKeywords(funcs)
+	funcs["GetqlFilter"] = func() string { return p.htmlID("getql-filter") }
+	funcs["GetqlFilterCheckbox"] = filterCheckbox(params, p.Key(p.Filter), p.htmlID("getql-filter-checkbox"))
+	funcs["GetqlFilterCheckboxLabel"] = func() string { return p.htmlID("getql-filter-checkbox") }
 	funcs["Input_Select"] = Select(params)
 	funcs["Input_Multiselect"] = Multiselect(params)
-	funcs["Input_SelectOptional"] = SelectOptional(params)
-	funcs["Input_MultiselectOptional"] = MultiselectOptional(params)
+	funcs["Input_SelectOptional"] = p.SelectOptional(params)
+	funcs["Input_MultiselectOptional"] = p.MultiselectOptional(params)
 	funcs["Input_Text"] = Text(params)
 	funcs["Input_Multitext"] = Multitext(params)
 	funcs["Input_Number"] = Number(params)
@@ -119,18 +130,29 @@ func Select(params map[string][]string) func(string, []KV, string, string) templ
 }
 
 func SelectOptional(params map[string][]string) func(string, []KV, string, string) template.HTML {
+	return DefaultParser.SelectOptional(params)
+}
+
+// SelectOptional is like Select, but with an option for p's Ignore token
+func (p Parser) SelectOptional(params map[string][]string) func(string, []KV, string, string) template.HTML {
 	return func(name string, values []KV, defaultValue, class string) template.HTML {
 		var newValues []KV
-		newValues = append(newValues, KV{Key: Ignore, Value: "(IGNORE)"})
+		newValues = append(newValues, KV{Key: p.Tokens.Ignore, Value: "(IGNORE)"})
 		newValues = append(newValues, values...)
 		return Select(params)(name, newValues, defaultValue, class)
 	}
 }
 
 func MultiselectOptional(params map[string][]string) func(string, []KV, string, string) template.HTML {
+	return DefaultParser.MultiselectOptional(params)
+}
+
+// MultiselectOptional is like Multiselect, but with an option for p's Ignore
+// token
+func (p Parser) MultiselectOptional(params map[string][]string) func(string, []KV, string, string) template.HTML {
 	return func(name string, values []KV, defaultValue, class string) template.HTML {
 		var newValues []KV
-		newValues = append(newValues, KV{Key: Ignore, Value: "(IGNORE)"})
+		newValues = append(newValues, KV{Key: p.Tokens.Ignore, Value: "(IGNORE)"})
 		newValues = append(newValues, values...)
 		return Multiselect(params)(name, newValues, defaultValue, class)
 	}
@@ -236,17 +258,30 @@ func DateNode(name, value, class string) (node *html.Node) {
 	}
 }
 
+// htmlID suffixes id with p's namespace, so that the ids of different
+// namespaces on the same page don't clash
+func (p Parser) htmlID(id string) string {
+	if p.Namespace == "" {
+		return id
+	}
+	return id + "-" + p.Namespace
+}
+
 func FilterCheckbox(params map[string][]string) func() template.HTML {
+	return filterCheckbox(params, Filter, "getql-filter-checkbox")
+}
+
+func filterCheckbox(params map[string][]string, filter, id string) func() template.HTML {
 	buf := &strings.Builder{}
 	node := &html.Node{
 		Type: html.ElementNode,
 		Data: "input",
 		Attr: []html.Attribute{
 			html.Attribute{Key: "type", Val: "checkbox"},
-			html.Attribute{Key: "id", Val: "getql-filter-checkbox"},
+			html.Attribute{Key: "id", Val: id},
 		},
 	}
-	paramvals := params[Filter]
+	paramvals := params[filter]
 	if len(paramvals) != 0 {
 		node.Attr = append(node.Attr, html.Attribute{Key: "checked", Val: "checked"})
 	}
@@ -302,64 +337,46 @@ func NumberNode(name, value, class string) (node *html.Node) {
 }
 
 func AddOperatorKV(funcs map[string]interface{}) map[string]interface{} {
-	funcs["GetqlOprKV"] = func() []KV {
-		return []KV{
-			KV{Eq, "is equal to"},
-			KV{Ne, "is not equal to"},
-			KV{In, "is one of"},
-			KV{Gt, "is greater than"},
-			KV{Ge, "is greater or equal to"},
-			KV{Lt, "is less than"},
-			KV{Le, "is less or equal to"},
-			KV{Null, "is null"},
-			KV{NotNull, "is not null"},
-			KV{Between, "is between"},
-			KV{Like, "is like"},
-			KV{ILike, "is ilike"},
-			KV{Ignore, "(IGNORE)"},
-		}
-	}
-	funcs["GetqlTextOprKV"] = func() []KV {
-		return []KV{
-			KV{Eq, "is equal to"},
-			KV{Ne, "is not equal to"},
-			KV{In, "is one of"},
-			KV{Null, "is null"},
-			KV{NotNull, "is not null"},
-			KV{Like, "is like"},
-			KV{ILike, "is ilike"},
-			KV{Ignore, "(IGNORE)"},
-		}
-	}
-	funcs["GetqlNumOprKV"] = func() []KV {
-		return []KV{
-			KV{Eq, "is equal to"},
-			KV{Ne, "is not equal to"},
-			KV{In, "is one of"},
-			KV{Gt, "is greater than"},
-			KV{Ge, "is greater or equal to"},
-			KV{Lt, "is less than"},
-			KV{Le, "is less or equal to"},
-			KV{Null, "is null"},
-			KV{NotNull, "is not null"},
-			KV{Between, "is between"},
-			KV{Ignore, "(IGNORE)"},
-		}
+	return DefaultParser.AddOperatorKV(funcs)
+}
+
+func (p Parser) AddOperatorKV(funcs map[string]interface{}) map[string]interface{} {
+	funcs["GetqlOprKV"] = func() []KV { return p.operatorKV(TypeAny) }
+	funcs["GetqlTextOprKV"] = func() []KV { return p.operatorKV(TypeText) }
+	funcs["GetqlNumOprKV"] = func() []KV { return p.operatorKV(TypeNumber) }
+	funcs["GetqlEnumOprKV"] = func() []KV { return p.operatorKV(TypeEnum) }
+	funcs["GetqlDateOprKV"] = func() []KV { return p.operatorKV(TypeDate) }
+	return funcs
+}
+
+// operatorKV lists the operators a column of type typ may be filtered with,
+// followed by Ignore
+func (p Parser) operatorKV(typ ColumnType) []KV {
+	t := p.Tokens
+	kvs := map[string]KV{
+		Eq:      KV{t.Eq, "is equal to"},
+		Ne:      KV{t.Ne, "is not equal to"},
+		In:      KV{t.In, "is one of"},
+		Gt:      KV{t.Gt, "is greater than"},
+		Ge:      KV{t.Ge, "is greater or equal to"},
+		Lt:      KV{t.Lt, "is less than"},
+		Le:      KV{t.Le, "is less or equal to"},
+		Null:    KV{t.Null, "is null"},
+		NotNull: KV{t.NotNull, "is not null"},
+		Between: KV{t.Between, "is between"},
+		Like:    KV{t.Like, "is like"},
+		ILike:   KV{t.ILike, "is ilike"},
 	}
-	funcs["GetqlEnumOprKV"] = func() []KV {
-		return []KV{
-			KV{Eq, "is equal to"},
-			KV{Ne, "is not equal to"},
-			KV{In, "is one of"},
-			KV{Null, "is null"},
-			KV{NotNull, "is not null"},
-			KV{Ignore, "(IGNORE)"},
-		}
+	var operators []KV
+	for _, operator := range typ.Operators() {
+		operators = append(operators, kvs[operator])
 	}
-	return funcs
+	return append(operators, KV{t.Ignore, "(IGNORE)"})
 }
 
-func Join(items ...interface{}) string {
+func Join(items ...interface{}) string { return DefaultParser.Join(items...) }
+
+func (p Parser) Join(items ...interface{}) string {
 	var strs []string
 	for _, item := range items {
 		switch v := item.(type) {
@@ -373,5 +390,5 @@ func Join(items ...interface{}) string {
 			strs = append(strs, strconv.FormatInt(int64(v), 10))
 		}
 	}
-	return strings.Join(strs, Sep)
+	return strings.Join(strs, p.Sep)
 }
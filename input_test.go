@@ -2,6 +2,8 @@ package getql
 
 import (
 	"fmt"
+	"html/template"
+	"strings"
 	"testing"
 )
 
@@ -18,3 +20,17 @@ func TestSelect(t *testing.T) {
 	x := Select(params)("nama", values, "IN", "ml2")
 	fmt.Println(x)
 }
+
+func TestSelectOptional(t *testing.T) {
+	p := DefaultParser
+	p.Tokens.Ignore = "skip"
+	funcs := p.Funcs(map[string]interface{}{}, map[string][]string{})
+	x := funcs["Input_SelectOptional"].(func(string, []KV, string, string) template.HTML)("1.ORD", []KV{{"name", "Name"}}, "", "")
+	if !strings.Contains(string(x), `<option value="skip">(IGNORE)</option>`) {
+		t.Errorf("got %s, want an option for the parser's ignore token", x)
+	}
+	x = funcs["Input_MultiselectOptional"].(func(string, []KV, string, string) template.HTML)("1.VAL", []KV{{"a", "A"}}, "", "")
+	if !strings.Contains(string(x), `<option value="skip">(IGNORE)</option>`) {
+		t.Errorf("got %s, want an option for the parser's ignore token", x)
+	}
+}
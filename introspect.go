@@ -0,0 +1,297 @@
+package getql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// IntrospectSchema builds the schema of table from the live database, reading
+// its columns from information_schema, or from pragmas for SQLite. Every column
+// gets PermAll and the Type that suits its DataType, so the schema should be
+// reviewed and trimmed before use, see Schema.GoSource. Foreign keys aren't
+// read for SQLServer.
+func IntrospectSchema(ctx context.Context, db Queryer, d Dialect, table string) (Schema, error) {
+	s := Schema{Table: table}
+	parts := splitQualified(table)
+	for i := range parts {
+		parts[i] = unquote(parts[i])
+	}
+	schemaName, tableName := "", parts[len(parts)-1]
+	if len(parts) > 1 {
+		schemaName = parts[len(parts)-2]
+	}
+	var err error
+	switch d.(type) {
+	case sqlite:
+		s.Columns, err = sqliteColumns(ctx, db, d, schemaName, tableName)
+	case postgres, mysql, sqlserver:
+		s.Columns, err = infoSchemaColumns(ctx, db, d, schemaName, tableName)
+	default:
+		return s, fmt.Errorf("getql: can't introspect %T", d)
+	}
+	if err != nil {
+		return s, err
+	}
+	if len(s.Columns) == 0 {
+		return s, fmt.Errorf("getql: table %s not found", table)
+	}
+	return s, nil
+}
+
+// currentSchema is the schema tables are in when they aren't qualified
+var currentSchema = map[Dialect]string{
+	Postgres:  "current_schema()",
+	MySQL:     "DATABASE()",
+	SQLServer: "SCHEMA_NAME()",
+}
+
+func infoSchemaColumns(ctx context.Context, db Queryer, d Dialect, schemaName, tableName string) (columns []Column, err error) {
+	// where filters by schemaName and tableName, in the table aliased as alias
+	where := func(alias string) string {
+		return " WHERE " + alias + "table_schema = COALESCE(NULLIF(" + d.Placeholder(1) + ", ''), " + currentSchema[d] + ")" +
+			" AND " + alias + "table_name = " + d.Placeholder(2)
+	}
+	rows, err := db.QueryContext(ctx, "SELECT column_name, data_type, is_nullable"+
+		" FROM information_schema.columns"+where("")+
+		" ORDER BY ordinal_position", schemaName, tableName)
+	if err != nil {
+		return columns, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var c Column
+		var nullable string
+		err = rows.Scan(&c.Name, &c.DataType, &nullable)
+		if err != nil {
+			return columns, err
+		}
+		c.Nullable = strings.EqualFold(nullable, "YES")
+		c.Type = dataType(c.DataType)
+		c.Perm = PermAll
+		columns = append(columns, c)
+	}
+	err = rows.Err()
+	if err != nil {
+		return columns, err
+	}
+	var query string
+	switch d {
+	case Postgres:
+		query = "SELECT kcu.column_name, ref.table_name, ref.column_name" +
+			" FROM information_schema.key_column_usage AS kcu" +
+			" JOIN information_schema.referential_constraints AS rc ON rc.constraint_schema = kcu.constraint_schema AND rc.constraint_name = kcu.constraint_name" +
+			" JOIN information_schema.key_column_usage AS ref ON ref.constraint_schema = rc.unique_constraint_schema AND ref.constraint_name = rc.unique_constraint_name AND ref.ordinal_position = kcu.position_in_unique_constraint" +
+			where("kcu.")
+	case MySQL:
+		query = "SELECT column_name, referenced_table_name, referenced_column_name" +
+			" FROM information_schema.key_column_usage" + where("") +
+			" AND referenced_table_name IS NOT NULL"
+	default:
+		return columns, nil
+	}
+	rows, err = db.QueryContext(ctx, query, schemaName, tableName)
+	if err != nil {
+		return columns, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var column, refTable, refColumn string
+		err = rows.Scan(&column, &refTable, &refColumn)
+		if err != nil {
+			return columns, err
+		}
+		setReference(columns, column, refTable+"."+refColumn)
+	}
+	return columns, rows.Err()
+}
+
+func sqliteColumns(ctx context.Context, db Queryer, d Dialect, schemaName, tableName string) (columns []Column, err error) {
+	pragma := "PRAGMA "
+	if schemaName != "" {
+		pragma += d.QuoteIdent(schemaName) + "."
+	}
+	rows, err := db.QueryContext(ctx, pragma+"table_info("+d.QuoteIdent(tableName)+")")
+	if err != nil {
+		return columns, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var c Column
+		var cid, notnull, pk int
+		var dflt sql.NullString
+		err = rows.Scan(&cid, &c.Name, &c.DataType, &notnull, &dflt, &pk)
+		if err != nil {
+			return columns, err
+		}
+		c.Nullable = notnull == 0 && pk == 0
+		c.Type = dataType(c.DataType)
+		c.Perm = PermAll
+		columns = append(columns, c)
+	}
+	err = rows.Err()
+	if err != nil {
+		return columns, err
+	}
+	rows, err = db.QueryContext(ctx, pragma+"foreign_key_list("+d.QuoteIdent(tableName)+")")
+	if err != nil {
+		return columns, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, seq int
+		var refTable, column, onUpdate, onDelete, match string
+		var refColumn sql.NullString
+		err = rows.Scan(&id, &seq, &refTable, &column, &refColumn, &onUpdate, &onDelete, &match)
+		if err != nil {
+			return columns, err
+		}
+		// A foreign key without a column references the primary key
+		if refColumn.Valid {
+			setReference(columns, column, refTable+"."+refColumn.String)
+		} else {
+			setReference(columns, column, refTable)
+		}
+	}
+	return columns, rows.Err()
+}
+
+func setReference(columns []Column, name, reference string) {
+	for i := range columns {
+		if columns[i].Name == name {
+			columns[i].References = reference
+		}
+	}
+}
+
+// dataTypes maps the names of types in the database, and the words of multi
+// word type names like "unsigned big int", to the column types that suit them
+var dataTypes = map[string]ColumnType{
+	"int": TypeNumber, "integer": TypeNumber, "bigint": TypeNumber, "smallint": TypeNumber,
+	"tinyint": TypeNumber, "mediumint": TypeNumber, "int2": TypeNumber, "int4": TypeNumber,
+	"int8": TypeNumber, "serial": TypeNumber, "bigserial": TypeNumber, "smallserial": TypeNumber,
+	"serial2": TypeNumber, "serial4": TypeNumber, "serial8": TypeNumber, "numeric": TypeNumber,
+	"decimal": TypeNumber, "real": TypeNumber, "double": TypeNumber, "float": TypeNumber,
+	"float4": TypeNumber, "float8": TypeNumber, "money": TypeNumber, "smallmoney": TypeNumber,
+	"number": TypeNumber,
+
+	"date": TypeDate, "datetime": TypeDate, "datetime2": TypeDate, "smalldatetime": TypeDate,
+	"datetimeoffset": TypeDate, "timestamp": TypeDate, "timestamptz": TypeDate, "time": TypeDate,
+	"timetz": TypeDate,
+
+	"bool": TypeEnum, "boolean": TypeEnum, "bit": TypeEnum, "enum": TypeEnum,
+	"user-defined": TypeEnum, "set": TypeEnum,
+
+	"char": TypeText, "character": TypeText, "varchar": TypeText, "nchar": TypeText,
+	"nvarchar": TypeText, "varchar2": TypeText, "nvarchar2": TypeText, "text": TypeText,
+	"tinytext": TypeText, "mediumtext": TypeText, "longtext": TypeText, "ntext": TypeText,
+	"clob": TypeText, "nclob": TypeText, "uuid": TypeText, "uniqueidentifier": TypeText,
+	"citext": TypeText, "string": TypeText,
+}
+
+// dataType returns the column type that suits a type in the database, by its
+// name without the size or precision. Types it doesn't know, like interval or
+// point, are TypeAny.
+func dataType(dbType string) ColumnType {
+	dbType = strings.ToLower(dbType)
+	if i := strings.IndexByte(dbType, '('); i >= 0 {
+		dbType = dbType[:i]
+	}
+	for _, word := range strings.Fields(dbType) {
+		if t, ok := dataTypes[word]; ok {
+			return t
+		}
+	}
+	return TypeAny
+}
+
+// GoSource returns Go code declaring s as a variable called name, for a
+// package that imports getql
+func (s Schema) GoSource(name string) string {
+	buf := &strings.Builder{}
+	fmt.Fprintf(buf, "var %s = getql.Schema{\n", name)
+	fmt.Fprintf(buf, "\tTable: %q,\n", s.Table)
+	buf.WriteString("\tColumns: []getql.Column{\n")
+	for _, c := range s.Columns {
+		fields := []string{fmt.Sprintf("Name: %q", c.Name)}
+		if c.Label != "" {
+			fields = append(fields, fmt.Sprintf("Label: %q", c.Label))
+		}
+		if c.Type != TypeAny {
+			fields = append(fields, "Type: "+goColumnType(c.Type))
+		}
+		fields = append(fields, "Perm: "+goPerm(c.Perm))
+		if len(c.Roles) > 0 {
+			var roles []string
+			for role := range c.Roles {
+				roles = append(roles, role)
+			}
+			sort.Strings(roles)
+			var entries []string
+			for _, role := range roles {
+				entries = append(entries, fmt.Sprintf("%q: %s", role, goPerm(c.Roles[role])))
+			}
+			fields = append(fields, "Roles: map[string]getql.Perm{"+strings.Join(entries, ", ")+"}")
+		}
+		if c.DataType != "" {
+			fields = append(fields, fmt.Sprintf("DataType: %q", c.DataType))
+		}
+		if c.Nullable {
+			fields = append(fields, "Nullable: true")
+		}
+		if c.References != "" {
+			fields = append(fields, fmt.Sprintf("References: %q", c.References))
+		}
+		buf.WriteString("\t\t{" + strings.Join(fields, ", ") + "},\n")
+	}
+	buf.WriteString("\t},\n")
+	if s.Policy == PolicyDrop {
+		buf.WriteString("\tPolicy: getql.PolicyDrop,\n")
+	}
+	if len(s.OrderBys) > 0 {
+		buf.WriteString("\tOrderBys: []getql.OrderBy{\n")
+		for _, orderBy := range s.OrderBys {
+			fields := []string{fmt.Sprintf("Column: %q", orderBy.Column), fmt.Sprintf("Order: %q", orderBy.Order)}
+			if orderBy.Nulls != "" {
+				fields = append(fields, fmt.Sprintf("Nulls: %q", orderBy.Nulls))
+			}
+			buf.WriteString("\t\t{" + strings.Join(fields, ", ") + "},\n")
+		}
+		buf.WriteString("\t},\n")
+	}
+	buf.WriteString("}\n")
+	return buf.String()
+}
+
+func goColumnType(t ColumnType) string {
+	switch t {
+	case TypeText:
+		return "getql.TypeText"
+	case TypeNumber:
+		return "getql.TypeNumber"
+	case TypeEnum:
+		return "getql.TypeEnum"
+	case TypeDate:
+		return "getql.TypeDate"
+	}
+	return fmt.Sprintf("getql.ColumnType(%q)", string(t))
+}
+
+func goPerm(perm Perm) string {
+	if perm == PermAll {
+		return "getql.PermAll"
+	}
+	names := map[Perm]string{PermSelect: "getql.PermSelect", PermFilter: "getql.PermFilter", PermSort: "getql.PermSort"}
+	var perms []string
+	for _, p := range []Perm{PermSelect, PermFilter, PermSort} {
+		if perm&p != 0 {
+			perms = append(perms, names[p])
+		}
+	}
+	if len(perms) == 0 {
+		return "0"
+	}
+	return strings.Join(perms, " | ")
+}
@@ -0,0 +1,105 @@
+package getql
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestIntrospectSchema(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+	db.MustExec(`CREATE TABLE orders (
+		id INTEGER PRIMARY KEY,
+		fruit_id INTEGER NOT NULL REFERENCES fruits (id),
+		note VARCHAR(100),
+		ordered_at DATETIME,
+		paid BOOLEAN
+	)`)
+	schema, err := IntrospectSchema(context.Background(), db, SQLite, "orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Schema{
+		Table: "orders",
+		Columns: []Column{
+			{Name: "id", Type: TypeNumber, Perm: PermAll, DataType: "INTEGER"},
+			{Name: "fruit_id", Type: TypeNumber, Perm: PermAll, DataType: "INTEGER", References: "fruits.id"},
+			{Name: "note", Type: TypeText, Perm: PermAll, DataType: "VARCHAR(100)", Nullable: true},
+			{Name: "ordered_at", Type: TypeDate, Perm: PermAll, DataType: "DATETIME", Nullable: true},
+			{Name: "paid", Type: TypeEnum, Perm: PermAll, DataType: "BOOLEAN", Nullable: true},
+		},
+	}
+	if !reflect.DeepEqual(schema, want) {
+		t.Errorf("got %+v, want %+v", schema, want)
+	}
+	if _, err := IntrospectSchema(context.Background(), db, SQLite, "missing"); err == nil {
+		t.Error("got nil error for a missing table")
+	}
+}
+
+func TestDataType(t *testing.T) {
+	tests := map[string]ColumnType{
+		"INTEGER":                     TypeNumber,
+		"unsigned big int":            TypeNumber,
+		"int4":                        TypeNumber,
+		"NUMERIC(10, 2)":              TypeNumber,
+		"double precision":            TypeNumber,
+		"timestamp without time zone": TypeDate,
+		"DATETIME":                    TypeDate,
+		"boolean":                     TypeEnum,
+		"USER-DEFINED":                TypeEnum,
+		"character varying":           TypeText,
+		"VARCHAR(255)":                TypeText,
+		"point":                       TypeAny,
+		"interval":                    TypeAny,
+		"jsonb":                       TypeAny,
+		"painting":                    TypeAny,
+	}
+	for dbType, want := range tests {
+		if got := dataType(dbType); got != want {
+			t.Errorf("dataType(%q) = %q, want %q", dbType, got, want)
+		}
+	}
+}
+
+func TestSchemaExport(t *testing.T) {
+	schema := Schema{
+		Table: "orders",
+		Columns: []Column{
+			{Name: "id", Type: TypeNumber, Perm: PermSelect | PermSort, DataType: "integer"},
+			{Name: "note", Label: "Note", Type: TypeText, Perm: PermAll, Roles: map[string]Perm{"guest": 0}, Nullable: true},
+		},
+		OrderBys: []OrderBy{{Column: "id", Order: Desc}},
+	}
+	wantGo := `var ordersSchema = getql.Schema{
+	Table: "orders",
+	Columns: []getql.Column{
+		{Name: "id", Type: getql.TypeNumber, Perm: getql.PermSelect | getql.PermSort, DataType: "integer"},
+		{Name: "note", Label: "Note", Type: getql.TypeText, Perm: getql.PermAll, Roles: map[string]getql.Perm{"guest": 0}, Nullable: true},
+	},
+	OrderBys: []getql.OrderBy{
+		{Column: "id", Order: "DESC"},
+	},
+}
+`
+	if got := schema.GoSource("ordersSchema"); got != wantGo {
+		t.Errorf("got\n%s\nwant\n%s", got, wantGo)
+	}
+	b, err := json.Marshal(schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantJSON := `{"table":"orders","columns":[{"name":"id","type":"number","perm":"select,sort","dataType":"integer"},{"name":"note","label":"Note","type":"text","perm":"select,filter,sort","roles":{"guest":""},"nullable":true}],"orderBys":[{"column":"id","order":"DESC"}]}`
+	if string(b) != wantJSON {
+		t.Errorf("got %s, want %s", b, wantJSON)
+	}
+	var decoded Schema
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, schema) {
+		t.Errorf("got %+v, want %+v", decoded, schema)
+	}
+}
@@ -0,0 +1,108 @@
+package getql
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Limits caps the shape of the queries a Parser accepts. A zero field means no
+// limit.
+type Limits struct {
+	MaxDepth    int // Maximum number of prefixes in a COL/OPR/VAL/AOR name, e.g. 2 for 4.1.COL
+	MaxPreds    int // Maximum number of predicates
+	MaxInValues int // Maximum number of values of a single predicate
+	MaxValueLen int // Maximum length of any parameter value
+	MaxLimit    int
+	MaxOffset   int
+	MaxOrderBys int
+}
+
+// ValidationError reports a parameter that was rejected
+type ValidationError struct {
+	Param   string `json:"param,omitempty"` // The offending parameter name, if any
+	Message string `json:"message"`
+}
+
+func (e ValidationError) Error() string {
+	if e.Param == "" {
+		return e.Message
+	}
+	return e.Param + ": " + e.Message
+}
+
+// ValidationErrors is the error returned when one or more parameters are
+// rejected
+type ValidationErrors []ValidationError
+
+func (errs ValidationErrors) Error() string {
+	msgs := make([]string, len(errs))
+	for i, e := range errs {
+		msgs[i] = e.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// err returns errs as an error, or nil if there are no errors
+func (errs ValidationErrors) err() error {
+	if len(errs) == 0 {
+		return nil
+	}
+	sort.SliceStable(errs, func(i, j int) bool { return errs[i].Param < errs[j].Param })
+	return errs
+}
+
+// checkValueLen reports whether every value is at most MaxValueLen long
+func (limits Limits) checkValueLen(param string, values []string, errs *ValidationErrors) bool {
+	if limits.MaxValueLen <= 0 {
+		return true
+	}
+	for _, value := range values {
+		if len(value) > limits.MaxValueLen {
+			*errs = append(*errs, ValidationError{Param: param, Message: "value longer than " + strconv.Itoa(limits.MaxValueLen) + " characters"})
+			return false
+		}
+	}
+	return true
+}
+
+// checkInValues truncates values to MaxInValues
+func (limits Limits) checkInValues(param string, values []string, errs *ValidationErrors) []string {
+	if limits.MaxInValues > 0 && len(values) > limits.MaxInValues {
+		*errs = append(*errs, ValidationError{Param: param, Message: "more than " + strconv.Itoa(limits.MaxInValues) + " values"})
+		values = values[:limits.MaxInValues]
+	}
+	return values
+}
+
+// checkLimits clamps the limit, offset, order bys and number of predicates of
+// query to p.Limits
+func (p Parser) checkLimits(query *SelectQuery, errs *ValidationErrors) {
+	limits := p.Limits
+	if limits.MaxLimit > 0 && query.Limit > limits.MaxLimit {
+		*errs = append(*errs, ValidationError{Param: p.Key(p.Suffixes.Lim), Message: "limit greater than " + strconv.Itoa(limits.MaxLimit)})
+		query.Limit = limits.MaxLimit
+	}
+	if limits.MaxOffset > 0 && query.Offset > limits.MaxOffset {
+		*errs = append(*errs, ValidationError{Param: p.Key(p.Suffixes.Off), Message: "offset greater than " + strconv.Itoa(limits.MaxOffset)})
+		query.Offset = limits.MaxOffset
+	}
+	if limits.MaxOrderBys > 0 && len(query.OrderBys) > limits.MaxOrderBys {
+		*errs = append(*errs, ValidationError{Param: p.Key(p.Suffixes.Ord), Message: "more than " + strconv.Itoa(limits.MaxOrderBys) + " order by columns"})
+		query.OrderBys = query.OrderBys[:limits.MaxOrderBys]
+	}
+	if limits.MaxPreds > 0 {
+		remaining, truncated := limits.MaxPreds, false
+		query.Where = Rewrite(query.Where, func(path []string, pred *Pred) *Pred {
+			if remaining <= 0 {
+				truncated = true
+				return nil
+			}
+			remaining--
+			return pred
+		})
+		if truncated {
+			*errs = append(*errs, ValidationError{Param: p.Key(p.Suffixes.Col), Message: "more than " + strconv.Itoa(limits.MaxPreds) + " predicates"})
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package getql
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLimits(t *testing.T) {
+	p := DefaultParser
+	p.Limits = Limits{
+		MaxDepth:    1,
+		MaxPreds:    2,
+		MaxInValues: 2,
+		MaxValueLen: 10,
+		MaxLimit:    100,
+		MaxOffset:   1000,
+		MaxOrderBys: 1,
+	}
+	params := map[string][]string{
+		Frm:           []string{"t"},
+		Col("1"):      []string{"a"},
+		Opr("1"):      []string{In},
+		Val("1"):      []string{"x", "y", "z"},
+		Col("2"):      []string{"b"},
+		Opr("2"):      []string{Eq},
+		Val("2"):      []string{strings.Repeat("b", 11)},
+		Col("3"):      []string{"c"},
+		Opr("3"):      []string{NotNull},
+		Col("4"):      []string{"d"},
+		Opr("4"):      []string{Null},
+		Col("5", "1"): []string{"e"},
+		Opr("5", "1"): []string{Null},
+		Ord("1"):      []string{"a", Asc},
+		Ord("2"):      []string{"b", Asc},
+		Lim:           []string{"1000000"},
+		Off:           []string{"1000000"},
+	}
+	query, err := p.Parse(params)
+	errs, ok := err.(ValidationErrors)
+	if !ok {
+		t.Fatalf("got %v, want ValidationErrors", err)
+	}
+	if len(errs) != 8 {
+		t.Errorf("got %d errors, want 8: %v", len(errs), errs)
+	}
+	got, args := query.Sql()
+	want := `FROM "t" WHERE "a" IN ($1, $2) AND "c" IS NOT NULL ORDER BY "a" ASC LIMIT 100 OFFSET 1000`
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if len(args) != 2 {
+		t.Errorf("got args %q", args)
+	}
+}
@@ -0,0 +1,238 @@
+package getql
+
+import (
+	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Literal renders v as an SQL literal for d. driver.Valuers are rendered as
+// the value they return, and any other type that isn't a basic driver value is
+// rendered as a JSON string.
+func Literal(d Dialect, v interface{}) (string, error) {
+	if valuer, ok := v.(driver.Valuer); ok {
+		if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr && rv.IsNil() {
+			return "NULL", nil
+		}
+		value, err := valuer.Value()
+		if err != nil {
+			return "", err
+		}
+		return Literal(d, value)
+	}
+	switch v := v.(type) {
+	case nil:
+		return "NULL", nil
+	case string:
+		return d.QuoteString(v), nil
+	case []byte:
+		if v == nil {
+			return "NULL", nil
+		}
+		return d.QuoteBytes(v), nil
+	case bool:
+		return d.Bool(v), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case int8:
+		return strconv.FormatInt(int64(v), 10), nil
+	case int16:
+		return strconv.FormatInt(int64(v), 10), nil
+	case int32:
+		return strconv.FormatInt(int64(v), 10), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case uint:
+		return strconv.FormatUint(uint64(v), 10), nil
+	case uint8:
+		return strconv.FormatUint(uint64(v), 10), nil
+	case uint16:
+		return strconv.FormatUint(uint64(v), 10), nil
+	case uint32:
+		return strconv.FormatUint(uint64(v), 10), nil
+	case uint64:
+		return strconv.FormatUint(v, 10), nil
+	case float32:
+		return formatFloat(d, float64(v), 32), nil
+	case float64:
+		return formatFloat(d, v, 64), nil
+	case time.Time:
+		return d.QuoteTime(v), nil
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return "NULL", nil
+		}
+		return Literal(d, rv.Elem().Interface())
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return d.QuoteString(string(b)), nil
+}
+
+// NaN and infinities have no numeric literal, so they are rendered as strings
+func formatFloat(d Dialect, f float64, bitSize int) string {
+	s := strconv.FormatFloat(f, 'g', -1, bitSize)
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return d.QuoteString(s)
+	}
+	return s
+}
+
+// substitute replaces every placeholder of d in query with the literal of the
+// corresponding arg. Placeholders are matched as whole tokens, so $1 never
+// matches the start of $10, and anything inside quotes is left alone.
+func substitute(d Dialect, query string, args []interface{}) (string, error) {
+	prefix := d.Placeholder(1)
+	positional := prefix == "?"
+	if !positional {
+		prefix = strings.TrimSuffix(prefix, "1")
+	}
+	identQuote := d.QuoteIdent("")[0]
+	buf := &strings.Builder{}
+	argIndex := 0
+	for i := 0; i < len(query); {
+		c := query[i]
+		// Copy quoted strings and identifiers verbatim
+		if c == '\'' || c == '"' || c == '`' || (c == '[' && identQuote == '[') {
+			closer := c
+			if c == '[' {
+				closer = ']'
+			}
+			j := i + 1
+			for j < len(query) {
+				if query[j] == closer {
+					if j+1 < len(query) && query[j+1] == closer {
+						j += 2
+						continue
+					}
+					break
+				}
+				j++
+			}
+			if j < len(query) {
+				j++
+			}
+			buf.WriteString(query[i:j])
+			i = j
+			continue
+		}
+		if positional && c == '?' {
+			if argIndex < len(args) {
+				lit, err := Literal(d, args[argIndex])
+				if err != nil {
+					return "", err
+				}
+				buf.WriteString(lit)
+			} else {
+				buf.WriteByte(c)
+			}
+			argIndex++
+			i++
+			continue
+		}
+		if !positional && strings.HasPrefix(query[i:], prefix) {
+			j := i + len(prefix)
+			for j < len(query) && query[j] >= '0' && query[j] <= '9' {
+				j++
+			}
+			n, err := strconv.Atoi(query[i+len(prefix) : j])
+			if err == nil && n >= 1 && n <= len(args) {
+				lit, err := Literal(d, args[n-1])
+				if err != nil {
+					return "", err
+				}
+				buf.WriteString(lit)
+				i = j
+				continue
+			}
+		}
+		buf.WriteByte(c)
+		i++
+	}
+	return buf.String(), nil
+}
+
+func quoteStringStandard(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+func (postgres) QuoteString(s string) string {
+	return quoteStringStandard(s)
+}
+
+func (postgres) QuoteBytes(b []byte) string {
+	return `'\x` + hex.EncodeToString(b) + "'::bytea"
+}
+
+func (postgres) QuoteTime(t time.Time) string {
+	return "'" + t.Format("2006-01-02 15:04:05.999999999Z07:00") + "'"
+}
+
+// MySQL treats backslashes in string literals as escapes (unless
+// NO_BACKSLASH_ESCAPES is set), so they are escaped the same way
+// mysql_real_escape_string does
+func (mysql) QuoteString(s string) string {
+	buf := &strings.Builder{}
+	buf.WriteByte('\'')
+	for _, r := range s {
+		switch r {
+		case 0:
+			buf.WriteString(`\0`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\x1a':
+			buf.WriteString(`\Z`)
+		case '\\':
+			buf.WriteString(`\\`)
+		case '\'':
+			buf.WriteString(`\'`)
+		default:
+			buf.WriteRune(r)
+		}
+	}
+	buf.WriteByte('\'')
+	return buf.String()
+}
+
+func (mysql) QuoteBytes(b []byte) string {
+	return "X'" + hex.EncodeToString(b) + "'"
+}
+
+func (mysql) QuoteTime(t time.Time) string {
+	return "'" + t.Format("2006-01-02 15:04:05.999999") + "'"
+}
+
+func (sqlite) QuoteString(s string) string {
+	return quoteStringStandard(s)
+}
+
+func (sqlite) QuoteBytes(b []byte) string {
+	return "X'" + hex.EncodeToString(b) + "'"
+}
+
+func (sqlite) QuoteTime(t time.Time) string {
+	return "'" + t.Format("2006-01-02 15:04:05.999999999-07:00") + "'"
+}
+
+func (sqlserver) QuoteString(s string) string {
+	return "N" + quoteStringStandard(s)
+}
+
+func (sqlserver) QuoteBytes(b []byte) string {
+	return "0x" + hex.EncodeToString(b)
+}
+
+func (sqlserver) QuoteTime(t time.Time) string {
+	return "'" + t.Format("2006-01-02T15:04:05.9999999") + "'"
+}
@@ -0,0 +1,60 @@
+package getql
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestLiteral(t *testing.T) {
+	ts := time.Date(2020, 3, 4, 5, 6, 7, 0, time.UTC)
+	tests := []struct {
+		dialect Dialect
+		value   interface{}
+		want    string
+	}{
+		{Postgres, "it's", `'it''s'`},
+		{Postgres, []byte{0xde, 0xad}, `'\xdead'::bytea`},
+		{Postgres, true, "TRUE"},
+		{Postgres, 1.5, "1.5"},
+		{Postgres, uint8(7), "7"},
+		{Postgres, ts, "'2020-03-04 05:06:07Z'"},
+		{Postgres, sql.NullString{String: "x", Valid: true}, "'x'"},
+		{Postgres, sql.NullInt64{}, "NULL"},
+		{Postgres, (*string)(nil), "NULL"},
+		{Postgres, []string{"a"}, `'["a"]'`},
+		{MySQL, `it's \ ok`, `'it\'s \\ ok'`},
+		{MySQL, []byte{0xde, 0xad}, "X'dead'"},
+		{SQLite, false, "0"},
+		{SQLServer, "it's", `N'it''s'`},
+		{SQLServer, []byte{0xde, 0xad}, "0xdead"},
+	}
+	for _, tt := range tests {
+		got, err := Literal(tt.dialect, tt.value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("%T %#v: got %s, want %s", tt.dialect, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestSubstDialect(t *testing.T) {
+	args := []interface{}{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j"}
+	got := Subst(`SELECT "$1" FROM t WHERE x = $1 AND y = $10 AND z = '$2'`, args...)
+	want := `SELECT "$1" FROM t WHERE x = 'a' AND y = 'j' AND z = '$2';`
+	if got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	got = SubstDialect(MySQL, "SELECT * FROM t WHERE x = ? AND `y?` = ?", "it's", 2)
+	want = "SELECT * FROM t WHERE x = 'it\\'s' AND `y?` = 2;"
+	if got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	got = SubstDialect(SQLServer, "SELECT * FROM t WHERE x = @p1 AND [@p2] = @p2", 1, 2)
+	want = "SELECT * FROM t WHERE x = 1 AND [@p2] = 2;"
+	if got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,181 @@
+package getql
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Normalize returns a copy of sq with its predicates normalized, see
+// PredGrp.Normalize. Equivalent filters normalize to the same SQL.
+func (sq SelectQuery) Normalize() SelectQuery {
+	return sq.normalize(false)
+}
+
+// normalize is Normalize, except that if keepIn is set IN with a single value
+// stays IN
+func (sq SelectQuery) normalize(keepIn bool) SelectQuery {
+	sq = sq.Clone()
+	sq.Select = dedup(removeEmptyStrings(sq.Select))
+	sq.Where = sq.Where.normalize(keepIn)
+	return sq
+}
+
+// Normalize returns a simplified copy of grp that renders to equivalent SQL:
+//
+//   - predicates without a column or with an ignored or unknown operator are
+//     removed, as are groups left empty
+//   - a group with a single predicate is replaced by that predicate
+//   - a group nested in a group with the same conjunction is flattened into it
+//   - IN with a single value becomes EQ, the values of IN are sorted
+//   - identical predicates are removed
+//   - the predicates of every group are sorted and renumbered 1, 2, 3...
+func (grp *PredGrp) Normalize() *PredGrp {
+	return grp.normalize(false)
+}
+
+func (grp *PredGrp) normalize(keepIn bool) *PredGrp {
+	if grp == nil {
+		return nil
+	}
+	preds := normalizePreds(grp, keepIn)
+	// The root doesn't need to be a group of one group
+	if len(preds) == 1 && preds[0].Nested {
+		return preds[0].PredGrp
+	}
+	return renumber(grp.Or, preds)
+}
+
+// normalizePreds returns the normalized predicates of grp in canonical order
+func normalizePreds(grp *PredGrp, keepIn bool) []*Pred {
+	var preds []*Pred
+	for _, key := range sortedKeys(grp.Preds) {
+		pred := grp.Preds[key]
+		if pred == nil {
+			continue
+		}
+		if !pred.Nested {
+			if pred = normalizePred(pred, keepIn); pred != nil {
+				preds = append(preds, pred)
+			}
+			continue
+		}
+		if pred.PredGrp == nil {
+			continue
+		}
+		nested := normalizePreds(pred.PredGrp, keepIn)
+		switch {
+		case len(nested) == 0:
+		case len(nested) == 1 && nested[0].Nested && nested[0].PredGrp.Or == grp.Or:
+			// A group of one group with grp's conjunction is flattened into grp
+			for _, key := range sortedKeys(nested[0].PredGrp.Preds) {
+				preds = append(preds, nested[0].PredGrp.Preds[key])
+			}
+		case len(nested) == 1:
+			preds = append(preds, nested[0])
+		case pred.PredGrp.Or == grp.Or:
+			preds = append(preds, nested...)
+		default:
+			preds = append(preds, &Pred{Nested: true, PredGrp: renumber(pred.PredGrp.Or, nested)})
+		}
+	}
+	seen := make(map[string]bool)
+	deduped := preds[:0]
+	for _, pred := range preds {
+		key := canonicalPred(pred, true)
+		if !seen[key] {
+			seen[key] = true
+			deduped = append(deduped, pred)
+		}
+	}
+	sort.SliceStable(deduped, func(i, j int) bool {
+		return canonicalPred(deduped[i], true) < canonicalPred(deduped[j], true)
+	})
+	return deduped
+}
+
+// normalizePred returns a normalized copy of a non-nested pred, or nil if it
+// renders to nothing
+func normalizePred(pred *Pred, keepIn bool) *Pred {
+	normalized := &Pred{
+		Column:   pred.Column,
+		Operator: strings.TrimSpace(pred.Operator),
+		Value:    pred.Value,
+		Values:   dedup(pred.Values),
+	}
+	if normalized.Column == "" {
+		return nil
+	}
+	switch normalized.Operator {
+	case Eq, Ne, Gt, Ge, Lt, Le, Like, ILike:
+		normalized.Values = []string{normalized.Value}
+	case Null, NotNull:
+		normalized.Value, normalized.Values = "", nil
+	case In:
+		switch len(normalized.Values) {
+		case 0:
+			return nil
+		case 1:
+			if !keepIn {
+				normalized.Operator = Eq
+			}
+		}
+		sort.Strings(normalized.Values)
+		normalized.Value = normalized.Values[0]
+	case Between:
+		if len(normalized.Values) < 2 {
+			return nil
+		}
+		normalized.Values = normalized.Values[:2]
+		normalized.Value = normalized.Values[0]
+	default:
+		return nil
+	}
+	return normalized
+}
+
+// renumber returns a group of preds keyed 1, 2, 3...
+func renumber(or bool, preds []*Pred) *PredGrp {
+	grp := &PredGrp{Or: or, Preds: make(map[string]*Pred, len(preds))}
+	for i, pred := range preds {
+		grp.Preds[strconv.Itoa(i+1)] = pred
+	}
+	return grp
+}
+
+// canonicalPred returns a string that is the same for normalized predicates
+// that render to the same SQL. If withValues is false, the string is the same
+// for predicates that only differ in their values.
+func canonicalPred(pred *Pred, withValues bool) string {
+	if !pred.Nested {
+		canonical := strconv.Quote(pred.Column) + space + pred.Operator
+		switch {
+		case withValues:
+			canonical += space + strings.Join(quoteAll(pred.Values), ",")
+		case pred.Operator == In:
+			canonical += space + "(...)"
+		}
+		return canonical
+	}
+	if pred.PredGrp == nil {
+		return "()"
+	}
+	conjuctor := And
+	if pred.PredGrp.Or {
+		conjuctor = Or
+	}
+	var children []string
+	for _, key := range sortedKeys(pred.PredGrp.Preds) {
+		children = append(children, canonicalPred(pred.PredGrp.Preds[key], withValues))
+	}
+	sort.Strings(children)
+	return "(" + strings.Join(children, space+conjuctor+space) + ")"
+}
+
+func quoteAll(values []string) []string {
+	quoted := make([]string, len(values))
+	for i, value := range values {
+		quoted[i] = strconv.Quote(value)
+	}
+	return quoted
+}
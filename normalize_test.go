@@ -0,0 +1,84 @@
+package getql
+
+import (
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	a := map[string][]string{
+		Frm:                []string{"t"},
+		Col("1"):           []string{"b"},
+		Opr("1"):           []string{In},
+		Val("1"):           []string{"y"},
+		Col("2", "1"):      []string{"a"},
+		Opr("2", "1"):      []string{In},
+		Val("2", "1"):      []string{"z", "x"},
+		Col("2", "2"):      []string{"b"},
+		Opr("2", "2"):      []string{Eq},
+		Val("2", "2"):      []string{"y"},
+		Col("3"):           []string{"ignored"},
+		Opr("3"):           []string{Ignore},
+		Aor("4"):           []string{Or},
+		Col("4", "1", "1"): []string{"c"},
+		Opr("4", "1", "1"): []string{Null},
+		Col("5"):           []string{"d"},
+		Opr("5"):           []string{Gt},
+		Val("5"):           []string{"1"},
+		Col("6", "1"):      []string{""},
+	}
+	b := map[string][]string{
+		Frm:      []string{"t"},
+		Col("1"): []string{"c"},
+		Opr("1"): []string{Null},
+		Col("2"): []string{"a"},
+		Opr("2"): []string{In},
+		Val("2"): []string{"x", "z"},
+		Col("3"): []string{"b"},
+		Opr("3"): []string{Eq},
+		Val("3"): []string{"y"},
+		Col("4"): []string{"d"},
+		Opr("4"): []string{Gt},
+		Val("4"): []string{"1"},
+	}
+	queryA, argsA := ParseSelect(a).Normalize().Sql()
+	queryB, argsB := ParseSelect(b).Normalize().Sql()
+	want := `FROM "t" WHERE "a" IN ($1, $2) AND "b" = $3 AND "c" IS NULL AND "d" > $4`
+	if queryA != want {
+		t.Errorf("got %q, want %q", queryA, want)
+	}
+	if queryB != want {
+		t.Errorf("got %q, want %q", queryB, want)
+	}
+	if len(argsA) != 4 || len(argsB) != 4 || argsA[0] != "x" || argsB[0] != "x" {
+		t.Errorf("got args %v and %v", argsA, argsB)
+	}
+}
+
+func TestNormalizeIdempotent(t *testing.T) {
+	// c AND (an OR group holding only a AND b)
+	params := map[string][]string{
+		Frm:                []string{"t"},
+		Col("1"):           []string{"c"},
+		Opr("1"):           []string{Eq},
+		Val("1"):           []string{"3"},
+		Aor("2"):           []string{Or},
+		Col("2", "1", "1"): []string{"a"},
+		Opr("2", "1", "1"): []string{Eq},
+		Val("2", "1", "1"): []string{"1"},
+		Col("2", "1", "2"): []string{"b"},
+		Opr("2", "1", "2"): []string{Eq},
+		Val("2", "1", "2"): []string{"2"},
+	}
+	once := ParseSelect(params).Normalize()
+	twice := once.Normalize()
+	want := `FROM "t" WHERE "a" = $1 AND "b" = $2 AND "c" = $3`
+	if query, _ := once.Sql(); query != want {
+		t.Errorf("got %q, want %q", query, want)
+	}
+	if query, _ := twice.Sql(); query != want {
+		t.Errorf("normalized again, got %q, want %q", query, want)
+	}
+	if once.Fingerprint() != twice.Fingerprint() {
+		t.Errorf("normalizing again changed the fingerprint")
+	}
+}
@@ -0,0 +1,418 @@
+package getql
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Parser holds the names of the URL query parameters and values that a
+// SelectQuery is read from. DefaultParser uses the package constants, copy it
+// and change the fields to use a different vocabulary.
+//
+// If Namespace is set, every parameter name is prefixed with Namespace and
+// NamespaceSep (e.g. orders:1.COL) so that several independent queries can
+// share the same URL.
+type Parser struct {
+	Sep          string // The separator between the prefixes and suffix
+	Suffixes     Suffixes
+	Tokens       Tokens
+	Page         string
+	Filter       string
+	Namespace    string
+	NamespaceSep string
+	Limits       Limits
+}
+
+// Suffixes are the names of the parameters, see the constants of the same name
+type Suffixes struct {
+	Sel string
+	Frm string
+	Col string
+	Opr string
+	Val string
+	Ord string
+	Lim string
+	Off string
+	Aor string
+}
+
+// Tokens are the parameter values that name operators, orderings and
+// conjunctions, see the constants of the same name
+type Tokens struct {
+	// Operators
+	Eq      string
+	Ne      string
+	In      string
+	Gt      string
+	Ge      string
+	Lt      string
+	Le      string
+	Null    string
+	NotNull string
+	Like    string
+	ILike   string
+	Between string
+	Ignore  string
+
+	// ORDER BY
+	Asc        string
+	Desc       string
+	NullsFirst string
+	NullsLast  string
+
+	// And/Or
+	And string
+	Or  string
+}
+
+var DefaultParser = Parser{
+	Sep: Sep,
+	Suffixes: Suffixes{
+		Sel: Sel,
+		Frm: Frm,
+		Col: col,
+		Opr: opr,
+		Val: val,
+		Ord: ord,
+		Lim: Lim,
+		Off: Off,
+		Aor: aor,
+	},
+	Tokens: Tokens{
+		Eq:         Eq,
+		Ne:         Ne,
+		In:         In,
+		Gt:         Gt,
+		Ge:         Ge,
+		Lt:         Lt,
+		Le:         Le,
+		Null:       Null,
+		NotNull:    NotNull,
+		Like:       Like,
+		ILike:      ILike,
+		Between:    Between,
+		Ignore:     Ignore,
+		Asc:        Asc,
+		Desc:       Desc,
+		NullsFirst: NullsFirst,
+		NullsLast:  NullsLast,
+		And:        And,
+		Or:         Or,
+	},
+	Page:         Page,
+	Filter:       Filter,
+	NamespaceSep: ":",
+}
+
+// WithNamespace returns a copy of p that reads and writes the parameters of
+// namespace ns
+func (p Parser) WithNamespace(ns string) Parser {
+	p.Namespace = ns
+	return p
+}
+
+// Key returns the parameter name for name in p's namespace
+func (p Parser) Key(name string) string {
+	if p.Namespace == "" {
+		return name
+	}
+	return p.Namespace + p.NamespaceSep + name
+}
+
+// trimNamespace returns key without p's namespace, and whether key belongs to
+// p's namespace at all. Without a namespace, keys that belong to any namespace
+// are not p's.
+func (p Parser) trimNamespace(key string) (name string, ok bool) {
+	if p.Namespace == "" {
+		if p.NamespaceSep != "" && strings.Contains(key, p.NamespaceSep) {
+			return key, false
+		}
+		return key, true
+	}
+	prefix := p.Namespace + p.NamespaceSep
+	if !strings.HasPrefix(key, prefix) {
+		return key, false
+	}
+	return key[len(prefix):], true
+}
+
+func (p Parser) IsValidSuffix(suffix string) bool {
+	suffixes := map[string]bool{
+		p.Suffixes.Sel: true,
+		p.Suffixes.Frm: true,
+		p.Suffixes.Col: true,
+		p.Suffixes.Opr: true,
+		p.Suffixes.Val: true,
+		p.Suffixes.Ord: true,
+		p.Suffixes.Lim: true,
+		p.Suffixes.Aor: true,
+		p.Page:         true,
+	}
+	return suffixes[suffix]
+}
+
+func (p Parser) Col(strs ...string) string {
+	return p.Key(strings.Join(append(strs, p.Suffixes.Col), p.Sep))
+}
+func (p Parser) Opr(strs ...string) string {
+	return p.Key(strings.Join(append(strs, p.Suffixes.Opr), p.Sep))
+}
+func (p Parser) Val(strs ...string) string {
+	return p.Key(strings.Join(append(strs, p.Suffixes.Val), p.Sep))
+}
+func (p Parser) Ord(strs ...string) string {
+	return p.Key(strings.Join(append(strs, p.Suffixes.Ord), p.Sep))
+}
+func (p Parser) Aor(strs ...string) string {
+	return p.Key(strings.Join(append(strs, p.Suffixes.Aor), p.Sep))
+}
+
+// operator returns the operator constant named by token, or an empty string if
+// token doesn't name an operator
+func (p Parser) operator(token string) string {
+	operators := map[string]string{
+		p.Tokens.Eq:      Eq,
+		p.Tokens.Ne:      Ne,
+		p.Tokens.In:      In,
+		p.Tokens.Gt:      Gt,
+		p.Tokens.Ge:      Ge,
+		p.Tokens.Lt:      Lt,
+		p.Tokens.Le:      Le,
+		p.Tokens.Null:    Null,
+		p.Tokens.NotNull: NotNull,
+		p.Tokens.Like:    Like,
+		p.Tokens.ILike:   ILike,
+		p.Tokens.Between: Between,
+		p.Tokens.Ignore:  Ignore,
+	}
+	return operators[strings.TrimSpace(token)]
+}
+
+// ScrubForm removes the parameters in p's namespace that aren't getql
+// parameters. The getql parameters of other namespaces are left alone, and
+// without a namespace of its own p removes every other parameter.
+func (p Parser) ScrubForm(form url.Values) url.Values {
+	for key, _ := range form {
+		name, ok := p.trimNamespace(key)
+		if !ok && p.Namespace != "" {
+			continue
+		}
+		if !ok {
+			// The name in another namespace, e.g. 1.COL of orders:1.COL
+			name = name[strings.Index(name, p.NamespaceSep)+len(p.NamespaceSep):]
+		}
+		strs := strings.Split(name, p.Sep)
+		suffix := strs[len(strs)-1]
+		if !p.IsValidSuffix(suffix) {
+			form.Del(key)
+		}
+	}
+	return form
+}
+
+func (p Parser) ScrubRequest(r *http.Request) *http.Request {
+	r.ParseForm()
+	p.ScrubForm(r.Form)
+	return r
+}
+
+func (p Parser) ScrubUrl(url string, form url.Values) string {
+	form = p.ScrubForm(form)
+	if len(form) > 0 {
+		url += "?" + form.Encode()
+	}
+	return url
+}
+
+// ParseSelect parses params into a SelectQuery. Anything that exceeds p.Limits
+// is silently dropped or clamped, use Parse to get the validation errors.
+func (p Parser) ParseSelect(params map[string][]string) (query SelectQuery) {
+	query, _ = p.Parse(params)
+	return query
+}
+
+// Parse parses params into a SelectQuery. If anything exceeds p.Limits, it
+// returns ValidationErrors along with the query with the offending parts
+// dropped or clamped.
+func (p Parser) Parse(params map[string][]string) (query SelectQuery, err error) {
+	var errs ValidationErrors
+	// Return first string from params[name], or empty string
+	paramvalue := func(name string) (value string) {
+		if values := params[name]; len(values) > 0 {
+			value = values[0]
+		}
+		return value
+	}
+	// Return first string from params[name] converted into int, or 0
+	paramvalueInt := func(name string) (value int) {
+		if values := params[name]; len(values) > 0 {
+			value, _ = strconv.Atoi(values[0]) // Don't care if it fails, zero value is fine
+		}
+		return value
+	}
+	query.Where = &PredGrp{}
+	query.Limit = paramvalueInt(p.Key(p.Suffixes.Lim))
+	query.Offset = paramvalueInt(p.Key(p.Suffixes.Off))
+	orderbyMap := make(map[string]OrderBy)
+	orderbyKeys := make([]string, 0)
+	var ref *PredGrp
+	rejected := make(map[string]bool) // The predicates with a rejected parameter
+	for key, values := range params {
+		name, ok := p.trimNamespace(key)
+		if !ok {
+			continue
+		}
+		strs := strings.Split(name, p.Sep)
+		if len(strs) == 0 {
+			continue
+		}
+		prefixes := strs[:len(strs)-1]
+		suffix := strs[len(strs)-1]
+		if !p.IsValidSuffix(suffix) {
+			continue
+		}
+		if p.Limits.MaxDepth > 0 && len(prefixes) > p.Limits.MaxDepth {
+			errs = append(errs, ValidationError{Param: key, Message: "nested deeper than " + strconv.Itoa(p.Limits.MaxDepth)})
+			continue
+		}
+		if !p.Limits.checkValueLen(key, values, &errs) {
+			rejected[strings.Join(prefixes, p.Sep)] = true
+			continue
+		}
+		value := paramvalue(key)
+		values = dedup(values)
+		ref = query.Where
+		switch suffix {
+		case p.Suffixes.Sel:
+			if len(prefixes) == 0 {
+				query.Select = removeEmptyStrings(values)
+			}
+		case p.Suffixes.Frm:
+			if len(prefixes) == 0 {
+				query.From = value
+			}
+		case p.Suffixes.Ord:
+			var orderby OrderBy
+			for _, value := range values {
+				if value == p.Tokens.Ignore {
+					orderby = OrderBy{}
+					break
+				}
+				switch value {
+				case p.Tokens.Asc:
+					orderby.Order = Asc
+				case p.Tokens.Desc:
+					orderby.Order = Desc
+				case p.Tokens.NullsFirst:
+					orderby.Nulls = First
+				case p.Tokens.NullsLast:
+					orderby.Nulls = Last
+				default:
+					if orderby.Column == "" {
+						orderby.Column = value
+					}
+				}
+			}
+			if orderby.String() != "" {
+				orderby.param = key
+				orderbyMap[name] = orderby
+				orderbyKeys = append(orderbyKeys, name)
+			}
+		case p.Suffixes.Col, p.Suffixes.Opr, p.Suffixes.Val, p.Suffixes.Aor:
+			for i, prefix := range prefixes {
+				if ref == nil {
+					ref = &PredGrp{}
+				}
+				if ref.Preds == nil {
+					ref.Preds = make(map[string]*Pred)
+				}
+				if ref.Preds[prefix] == nil {
+					ref.Preds[prefix] = &Pred{}
+				}
+				if i == len(prefixes)-1 {
+					switch suffix {
+					case p.Suffixes.Col:
+						ref.Preds[prefix].Column = value
+					case p.Suffixes.Opr:
+						ref.Preds[prefix].Operator = p.operator(value)
+					case p.Suffixes.Val:
+						ref.Preds[prefix].Value = value
+						ref.Preds[prefix].Values = p.Limits.checkInValues(key, values, &errs)
+					case p.Suffixes.Aor:
+						if ref.Preds[prefix].PredGrp == nil {
+							ref.Preds[prefix].PredGrp = &PredGrp{}
+						}
+						ref.Preds[prefix].PredGrp.Or = value == p.Tokens.Or
+					}
+					break
+				}
+				if ref.Preds[prefix].PredGrp == nil {
+					ref.Preds[prefix].PredGrp = &PredGrp{}
+				}
+				ref.Preds[prefix].Nested = true
+				ref = ref.Preds[prefix].PredGrp
+			}
+		}
+	}
+	sort.Strings(orderbyKeys)
+	for _, key := range orderbyKeys {
+		orderby := orderbyMap[key]
+		if orderby.String() != "" {
+			query.OrderBys = append(query.OrderBys, orderby)
+		}
+	}
+	if len(rejected) > 0 {
+		query.Where = Rewrite(query.Where, func(path []string, pred *Pred) *Pred {
+			if rejected[strings.Join(path, p.Sep)] {
+				return nil
+			}
+			return pred
+		})
+	}
+	p.checkLimits(&query, &errs)
+	return query, errs.err()
+}
+
+func (p Parser) ResolvePage(params map[string][]string) map[string][]string {
+	limit := 5
+	page := 1
+	if params[p.Key(p.Suffixes.Lim)] != nil {
+		l, _ := strconv.Atoi(params[p.Key(p.Suffixes.Lim)][0])
+		if l > limit {
+			limit = l
+		}
+	}
+	if params[p.Key(p.Page)] != nil {
+		pg, _ := strconv.Atoi(params[p.Key(p.Page)][0])
+		if pg > page {
+			page = pg
+		}
+	}
+	offset := limit * (page - 1)
+	params[p.Key(p.Suffixes.Off)] = []string{strconv.Itoa(offset)}
+	return params
+}
+
+func (p Parser) PaginateHandlerFunc(url string, delta int, errorHandler func(http.ResponseWriter, *http.Request, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := r.ParseForm()
+		if err != nil {
+			errorHandler(w, r, err)
+			return
+		}
+		params := p.ScrubForm(r.Form)
+		page, _ := strconv.Atoi(r.FormValue(p.Key(p.Page)))
+		if page != 0 {
+			page += delta
+			params[p.Key(p.Page)] = []string{strconv.Itoa(page)}
+		}
+		if page == 0 {
+			params[p.Key(p.Page)] = []string{"1"}
+		}
+		newUrl := p.ScrubUrl(url, params)
+		http.Redirect(w, r, newUrl, http.StatusMovedPermanently)
+	}
+}
@@ -0,0 +1,89 @@
+package getql
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestParserVocabulary(t *testing.T) {
+	p := DefaultParser
+	p.Sep = "_"
+	p.Suffixes.Sel = "fields"
+	p.Suffixes.Frm = "table"
+	p.Suffixes.Col = "col"
+	p.Suffixes.Opr = "op"
+	p.Suffixes.Val = "val"
+	p.Suffixes.Ord = "sort"
+	p.Tokens.Eq = "is"
+	p.Tokens.Desc = "desc"
+	params := url.Values{
+		"fields":   []string{"a"},
+		"table":    []string{"t"},
+		p.Col("1"): []string{"name"},
+		p.Opr("1"): []string{"is"},
+		p.Val("1"): []string{"bob"},
+		p.Ord("1"): []string{"a", "desc"},
+		"utm":      []string{"x"},
+	}
+	query, args := p.ParseSelect(params).Sql()
+	want := `SELECT "a" FROM "t" WHERE "name" = $1 ORDER BY "a" DESC`
+	if query != want {
+		t.Errorf("got %q, want %q", query, want)
+	}
+	if len(args) != 1 || args[0] != "bob" {
+		t.Errorf("got args %v", args)
+	}
+	p.ScrubForm(params)
+	if _, ok := params["utm"]; ok {
+		t.Errorf("ScrubForm kept utm")
+	}
+	if _, ok := params["1_col"]; !ok {
+		t.Errorf("ScrubForm removed 1_col")
+	}
+}
+
+func TestParserNamespace(t *testing.T) {
+	orders := DefaultParser.WithNamespace("orders")
+	customers := DefaultParser.WithNamespace("customers")
+	params := url.Values{
+		orders.Key(Frm):       []string{"orders"},
+		orders.Col("1"):       []string{"status"},
+		orders.Opr("1"):       []string{Eq},
+		orders.Val("1"):       []string{"paid"},
+		orders.Key(Page):      []string{"2"},
+		customers.Key(Frm):    []string{"customers"},
+		customers.Col("1"):    []string{"name"},
+		customers.Opr("1"):    []string{Eq},
+		customers.Val("1"):    []string{"bob"},
+		customers.Key("junk"): []string{"x"},
+	}
+	query, _ := orders.ParseSelect(params).Sql()
+	if want := `FROM "orders" WHERE "status" = $1`; query != want {
+		t.Errorf("got %q, want %q", query, want)
+	}
+	query, _ = customers.ParseSelect(params).Sql()
+	if want := `FROM "customers" WHERE "name" = $1`; query != want {
+		t.Errorf("got %q, want %q", query, want)
+	}
+	if query, _ = ParseSelect(params).Sql(); query != "" {
+		t.Errorf("default parser read namespaced params: %q", query)
+	}
+	customers.ScrubForm(params)
+	if _, ok := params["customers:junk"]; ok {
+		t.Errorf("ScrubForm kept customers:junk")
+	}
+	if _, ok := params["orders:PAGE"]; !ok {
+		t.Errorf("ScrubForm removed orders:PAGE")
+	}
+	params.Set("utm:source", "newsletter")
+	DefaultParser.ScrubForm(params)
+	if _, ok := params["utm:source"]; ok {
+		t.Errorf("ScrubForm kept utm:source")
+	}
+	if _, ok := params["orders:PAGE"]; !ok {
+		t.Errorf("ScrubForm removed orders:PAGE")
+	}
+	if _, ok := params[customers.Col("1")]; !ok {
+		t.Errorf("ScrubForm removed %s", customers.Col("1"))
+	}
+}
@@ -0,0 +1,25 @@
+module github.com/bokwoon95/getql/pgxql
+
+go 1.18
+
+require (
+	github.com/bokwoon95/getql v0.0.0
+	github.com/jackc/pgx/v4 v4.18.1
+)
+
+require (
+	github.com/jackc/chunkreader/v2 v2.0.1 // indirect
+	github.com/jackc/pgconn v1.14.0 // indirect
+	github.com/jackc/pgio v1.0.0 // indirect
+	github.com/jackc/pgpassfile v1.0.0 // indirect
+	github.com/jackc/pgproto3/v2 v2.3.2 // indirect
+	github.com/jackc/pgservicefile v0.0.0-20221227161230-091c0ba34f0a // indirect
+	github.com/jackc/pgtype v1.14.0 // indirect
+	github.com/jmoiron/sqlx v1.2.0 // indirect
+	golang.org/x/crypto v0.6.0 // indirect
+	golang.org/x/net v0.6.0 // indirect
+	golang.org/x/text v0.7.0 // indirect
+)
+
+// Develop against the getql in the parent directory
+replace github.com/bokwoon95/getql => ../
@@ -0,0 +1,139 @@
+// Package pgxql runs getql queries with jackc/pgx instead of database/sql.
+package pgxql
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/bokwoon95/getql"
+	"github.com/jackc/pgx/v4"
+)
+
+// Querier is satisfied by *pgx.Conn, *pgxpool.Pool and pgx.Tx
+type Querier interface {
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+	SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults
+}
+
+// SelectWithStats is like getql.DBSelectWithStatsContext, but runs the queries
+// on db. The count and the page of results are sent in one batch, unless the
+// total is cached or has its own timeout. getql.CountEstimated is counted
+// exactly, see getql.SelectPlan.CountSql. Like getql.DBSelectWithStats, it
+// returns an error for getql.CountWindow and getql.CountNone, which need the
+// rows to be read up front. The dialect defaults to getql.Postgres.
+func SelectWithStats(ctx context.Context, db Querier, params map[string][]string, options ...getql.SelectStatsOption) (rows pgx.Rows, stats getql.SelectStats, err error) {
+	options = append([]getql.SelectStatsOption{getql.SelectStatsDialect(getql.Postgres)}, options...)
+	plan, err := getql.NewSelectPlan(params, options...)
+	if err != nil {
+		return rows, plan.Stats, err
+	}
+	config := plan.Config()
+	if config.Count == getql.CountWindow || config.Count == getql.CountNone {
+		return rows, plan.Stats, errMaterialize
+	}
+	query, args := plan.Query.Sql()
+	if total, ok := plan.CachedTotal(); ok {
+		plan.SetTotal(total)
+		rows, err = selectRows(ctx, db, config, query, args)
+		return rows, plan.Stats, err
+	}
+	if config.CountTimeout > 0 {
+		err = count(ctx, db, &plan)
+		if err != nil {
+			return rows, plan.Stats, err
+		}
+		rows, err = selectRows(ctx, db, config, query, args)
+		return rows, plan.Stats, err
+	}
+	// Send the count and the page of results in one round trip
+	batchCtx, cancel := withTimeout(ctx, config.QueryTimeout)
+	batch := &pgx.Batch{}
+	countQuery, countArgs := plan.CountSql()
+	batch.Queue(countQuery, countArgs...)
+	batch.Queue(query, args...)
+	results := db.SendBatch(batchCtx, batch)
+	var total int
+	err = results.QueryRow().Scan(&total)
+	if err != nil {
+		results.Close()
+		cancel()
+		return rows, plan.Stats, err
+	}
+	plan.SetTotal(total)
+	rows, err = results.Query()
+	if err != nil {
+		results.Close()
+		cancel()
+		return nil, plan.Stats, err
+	}
+	return &batchRows{Rows: rows, results: results, cancel: cancel}, plan.Stats, nil
+}
+
+var errMaterialize = errors.New("pgxql: getql.CountWindow and getql.CountNone need the rows to be read up front, use getql.DBSelectMapsWithStats")
+
+// count fills in the plan's total, or Stats.TotalUnknown if the count times
+// out
+func count(ctx context.Context, db Querier, plan *getql.SelectPlan) error {
+	countCtx, cancel := withTimeout(ctx, plan.Config().CountTimeout)
+	defer cancel()
+	query, args := plan.CountSql()
+	var total int
+	err := db.QueryRow(countCtx, query, args...).Scan(&total)
+	if err != nil {
+		if ctx.Err() == nil && countCtx.Err() == context.DeadlineExceeded {
+			plan.Stats.TotalUnknown = true
+			return nil
+		}
+		return err
+	}
+	plan.SetTotal(total)
+	return nil
+}
+
+// selectRows runs the query for the page of results
+func selectRows(ctx context.Context, db Querier, config getql.SelectStatsConfig, query string, args []interface{}) (pgx.Rows, error) {
+	queryCtx, cancel := withTimeout(ctx, config.QueryTimeout)
+	rows, err := db.Query(queryCtx, query, args...)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	// The caller reads the rows after we return, so queryCtx can only be
+	// released once the timeout is up
+	if config.QueryTimeout > 0 {
+		time.AfterFunc(config.QueryTimeout, cancel)
+	}
+	return rows, nil
+}
+
+// withTimeout returns ctx with timeout applied, if there is one
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// batchRows closes the batch along with the rows, since the connection is held
+// until the batch is closed
+type batchRows struct {
+	pgx.Rows
+	results pgx.BatchResults
+	cancel  context.CancelFunc
+}
+
+func (rows *batchRows) Next() bool {
+	if rows.Rows.Next() {
+		return true
+	}
+	rows.Close()
+	return false
+}
+
+func (rows *batchRows) Close() {
+	rows.Rows.Close()
+	rows.results.Close()
+	rows.cancel()
+}
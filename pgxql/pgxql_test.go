@@ -0,0 +1,78 @@
+package pgxql
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/bokwoon95/getql"
+	"github.com/jackc/pgx/v4"
+)
+
+// connect connects to the database in PGXQL_TEST_DATABASE, or skips the test
+func connect(t *testing.T) *pgx.Conn {
+	dsn := os.Getenv("PGXQL_TEST_DATABASE")
+	if dsn == "" {
+		t.Skip("PGXQL_TEST_DATABASE not set")
+	}
+	conn, err := pgx.Connect(context.Background(), dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+func TestSelectWithStats(t *testing.T) {
+	conn := connect(t)
+	defer conn.Close(context.Background())
+	ctx := context.Background()
+	_, err := conn.Exec(ctx, `CREATE TEMP TABLE fruits AS
+	SELECT i AS id, (ARRAY['red', 'green', 'yellow'])[i % 3 + 1] AS color
+	FROM generate_series(1, 12) AS i`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	params := map[string][]string{
+		getql.Sel:      []string{"id"},
+		getql.Frm:      []string{"fruits"},
+		getql.Col("1"): []string{"color"},
+		getql.Opr("1"): []string{getql.In},
+		getql.Val("1"): []string{"red", "green"},
+		getql.Ord("1"): []string{"id", getql.Asc},
+		getql.Page:     []string{"2"},
+	}
+	cache := getql.NewLRUCache(8)
+	// The first run batches the count, the second uses the cached count
+	for i := 0; i < 2; i++ {
+		rows, stats, err := SelectWithStats(ctx, conn, params,
+			getql.SelectStatsCache(cache, time.Minute, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []int32
+		for rows.Next() {
+			var id int32
+			if err := rows.Scan(&id); err != nil {
+				t.Fatal(err)
+			}
+			ids = append(ids, id)
+		}
+		if err := rows.Err(); err != nil {
+			t.Fatal(err)
+		}
+		if stats.Total != 8 || stats.TotalPages != 2 || len(ids) != 3 {
+			t.Errorf("got stats %+v, ids %v", stats, ids)
+		}
+	}
+}
+
+func TestSelectWithStatsMaterialize(t *testing.T) {
+	params := map[string][]string{getql.Frm: []string{"fruits"}}
+	for _, strategy := range []getql.CountStrategy{getql.CountWindow, getql.CountNone} {
+		_, _, err := SelectWithStats(context.Background(), nil, params, getql.SelectStatsCount(strategy))
+		if err != errMaterialize {
+			t.Errorf("got %v, want errMaterialize", err)
+		}
+	}
+}
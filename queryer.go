@@ -0,0 +1,33 @@
+package getql
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Queryer is what DBSelectWithStats needs to run its queries. It is satisfied
+// by *sqlx.DB, *sqlx.Tx, *sql.DB and *sql.Tx.
+type Queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// queryerx is implemented by *sqlx.DB and *sqlx.Tx, so that their own mapper
+// is used for StructScan
+type queryerx interface {
+	QueryxContext(ctx context.Context, query string, args ...interface{}) (*sqlx.Rows, error)
+}
+
+// queryx runs query on q and returns the rows as *sqlx.Rows
+func queryx(ctx context.Context, q Queryer, query string, args ...interface{}) (*sqlx.Rows, error) {
+	if qx, ok := q.(queryerx); ok {
+		return qx.QueryxContext(ctx, query, args...)
+	}
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return &sqlx.Rows{Rows: rows, Mapper: mapper}, nil
+}
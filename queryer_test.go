@@ -0,0 +1,51 @@
+package getql
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestQueryer(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+	params := map[string][]string{
+		Sel:      []string{"id", "name"},
+		Frm:      []string{"fruits"},
+		Col("1"): []string{"color"},
+		Opr("1"): []string{Eq},
+		Val("1"): []string{"yellow"},
+		Ord("1"): []string{"id", Asc},
+	}
+	check := func(q Queryer) {
+		rows, stats, err := DBSelectWithStats(q, params, SelectStatsDialect(SQLite))
+		if err != nil {
+			t.Fatalf("%T: %v", q, err)
+		}
+		defer rows.Close()
+		var ids []int
+		for rows.Next() {
+			var fruit struct {
+				ID   int    `db:"id"`
+				Name string `db:"name"`
+			}
+			if err := rows.StructScan(&fruit); err != nil {
+				t.Fatalf("%T: %v", q, err)
+			}
+			ids = append(ids, fruit.ID)
+		}
+		if stats.Total != 4 || fmt.Sprint(ids) != "[2 5 8 11]" {
+			t.Errorf("%T: got total %d, ids %v", q, stats.Total, ids)
+		}
+	}
+	check(db)
+	check(db.DB)
+	// The test database has a single connection, which the transaction holds
+	// on to, so it is only started once the queries on db are done
+	tx, err := db.Beginx()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	check(tx)
+	check(tx.Tx)
+}
@@ -0,0 +1,399 @@
+package getql
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Perm is a set of permissions on a column
+type Perm int
+
+const (
+	PermSelect Perm = 1 << iota // The column may be selected
+	PermFilter                  // The column may be used in a predicate
+	PermSort                    // The column may be ordered by
+	PermAll    = PermSelect | PermFilter | PermSort
+)
+
+type Column struct {
+	Name       string          `json:"name"`
+	Label      string          `json:"label,omitempty"`
+	Type       ColumnType      `json:"type,omitempty"`
+	Perm       Perm            `json:"perm"`                 // Permissions of any role not in Roles
+	Roles      map[string]Perm `json:"roles,omitempty"`      // Permissions of specific roles
+	DataType   string          `json:"dataType,omitempty"`   // The type in the database, see IntrospectSchema
+	Nullable   bool            `json:"nullable,omitempty"`   // See IntrospectSchema
+	References string          `json:"references,omitempty"` // The table.column of a foreign key, see IntrospectSchema
+}
+
+var permNames = []struct {
+	perm Perm
+	name string
+}{{PermSelect, "select"}, {PermFilter, "filter"}, {PermSort, "sort"}}
+
+// MarshalText lists the permissions in perm, like "select,filter"
+func (perm Perm) MarshalText() ([]byte, error) {
+	var names []string
+	for _, p := range permNames {
+		if perm&p.perm != 0 {
+			names = append(names, p.name)
+		}
+	}
+	return []byte(strings.Join(names, ",")), nil
+}
+
+// UnmarshalText parses the permissions listed by MarshalText
+func (perm *Perm) UnmarshalText(text []byte) error {
+	*perm = 0
+	for _, name := range strings.Split(string(text), ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		found := false
+		for _, p := range permNames {
+			if p.name == name {
+				*perm |= p.perm
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("getql: unknown permission %q", name)
+		}
+	}
+	return nil
+}
+
+// Can reports whether role has every permission in perm on c
+func (c Column) Can(role string, perm Perm) bool {
+	granted, ok := c.Roles[role]
+	if !ok {
+		granted = c.Perm
+	}
+	return granted&perm == perm
+}
+
+// ColumnType decides which operators a column may be filtered with
+type ColumnType string
+
+const (
+	TypeAny    ColumnType = ""
+	TypeText   ColumnType = "text"
+	TypeNumber ColumnType = "number"
+	TypeEnum   ColumnType = "enum"
+	TypeDate   ColumnType = "date"
+)
+
+// Operators returns the operators a column of type t may be filtered with,
+// in the order they are usually listed
+func (t ColumnType) Operators() []string {
+	switch t {
+	case TypeText:
+		return []string{Eq, Ne, In, Null, NotNull, Like, ILike}
+	case TypeNumber, TypeDate:
+		return []string{Eq, Ne, In, Gt, Ge, Lt, Le, Null, NotNull, Between}
+	case TypeEnum:
+		return []string{Eq, Ne, In, Null, NotNull}
+	}
+	return []string{Eq, Ne, In, Gt, Ge, Lt, Le, Null, NotNull, Between, Like, ILike}
+}
+
+// Input returns the name of the template func from Funcs that renders an input
+// for values of type t
+func (t ColumnType) Input() string {
+	switch t {
+	case TypeNumber:
+		return "Input_Number"
+	case TypeDate:
+		return "Input_Date"
+	case TypeEnum:
+		return "Input_Select"
+	}
+	return "Input_Text"
+}
+
+// permits reports whether operator may filter a column of type t. Ignore and
+// the empty operator filter nothing, so every type permits them.
+func (t ColumnType) permits(operator string) bool {
+	if operator == Ignore || operator == "" {
+		return true
+	}
+	for _, o := range t.Operators() {
+		if o == operator {
+			return true
+		}
+	}
+	return false
+}
+
+// Policy decides what Schema.Authorize does with the columns a role isn't
+// permitted to use
+type Policy int
+
+const (
+	PolicyReject Policy = iota // Reject the query with ValidationErrors
+	PolicyDrop                 // Silently drop the columns from the query
+)
+
+// Schema lists the columns of a table that may be queried, and who may query
+// them
+type Schema struct {
+	Table    string    `json:"table"`
+	Columns  []Column  `json:"columns"`
+	Policy   Policy    `json:"policy,omitempty"`
+	OrderBys []OrderBy `json:"orderBys,omitempty"` // The order of queries that don't have one
+}
+
+// Column returns the column called name
+func (s Schema) Column(name string) (Column, bool) {
+	for _, c := range s.Columns {
+		if c.Name == name {
+			return c, true
+		}
+	}
+	return Column{}, false
+}
+
+// Permitted returns the columns role has every permission in perm on
+func (s Schema) Permitted(role string, perm Perm) (columns []Column) {
+	for _, c := range s.Columns {
+		if c.Can(role, perm) {
+			columns = append(columns, c)
+		}
+	}
+	return columns
+}
+
+func (s Schema) can(role, name string, perm Perm) bool {
+	c, ok := s.Column(name)
+	return ok && c.Can(role, perm)
+}
+
+// Authorize checks that role may use every column that sq selects, filters or
+// sorts by, according to s.Policy, and that the operators suit the columns'
+// types. Selecting * or nothing at all selects every column role may select.
+// The query always reads from s.Table, if set.
+func (s Schema) Authorize(sq SelectQuery, role string) (SelectQuery, error) {
+	return s.AuthorizeWith(DefaultParser, sq, role)
+}
+
+// AuthorizeWith is Authorize for a query parsed by p, whose ValidationErrors
+// name the parameters in p's vocabulary and namespace
+func (s Schema) AuthorizeWith(p Parser, sq SelectQuery, role string) (SelectQuery, error) {
+	var errs ValidationErrors
+	sq = s.authorize(p, sq, role, &errs)
+	if s.Policy == PolicyDrop {
+		return sq, nil
+	}
+	return sq, errs.err()
+}
+
+// Restrict is a SelectOption that silently drops every column role may not
+// use, and expands * into the columns role may select. Count and WindowCount,
+// which only getql's own options select, are kept.
+func (s Schema) Restrict(role string) SelectOption {
+	return func(sq SelectQuery) SelectQuery {
+		var counts []string
+		for _, name := range sq.Select {
+			if name == Count || name == WindowCount {
+				counts = append(counts, name)
+			}
+		}
+		sq = s.authorize(DefaultParser, sq, role, &ValidationErrors{})
+		sq.Select = append(sq.Select, counts...)
+		return sq
+	}
+}
+
+func (s Schema) authorize(p Parser, sq SelectQuery, role string, errs *ValidationErrors) SelectQuery {
+	if s.Table != "" {
+		if sq.From != "" && sq.From != s.Table {
+			*errs = append(*errs, ValidationError{Param: p.Key(p.Suffixes.Frm), Message: "table not permitted: " + sq.From})
+		}
+		sq.From = s.Table
+	}
+	var selects []string
+	for _, name := range sq.Select {
+		switch {
+		case name == "*":
+			for _, c := range s.Permitted(role, PermSelect) {
+				selects = append(selects, c.Name)
+			}
+		case s.can(role, name, PermSelect):
+			selects = append(selects, name)
+		default:
+			*errs = append(*errs, ValidationError{Param: p.Key(p.Suffixes.Sel), Message: "column not permitted: " + name})
+		}
+	}
+	if len(sq.Select) == 0 {
+		for _, c := range s.Permitted(role, PermSelect) {
+			selects = append(selects, c.Name)
+		}
+	}
+	sq.Select = dedup(selects)
+	sq.Where = s.authorizeWhere(p, sq.Where, role, errs)
+	var orderBys []OrderBy
+	for _, orderby := range sq.OrderBys {
+		if s.can(role, orderby.Column, PermSort) {
+			orderBys = append(orderBys, orderby)
+		} else {
+			param := orderby.param
+			if param == "" {
+				param = p.Key(p.Suffixes.Ord)
+			}
+			*errs = append(*errs, ValidationError{Param: param, Message: "column not permitted: " + orderby.Column})
+		}
+	}
+	if len(orderBys) == 0 {
+		orderBys = append(orderBys, s.OrderBys...)
+	}
+	sq.OrderBys = orderBys
+	return sq
+}
+
+// authorizeWhere returns a copy of grp without the predicates on columns role
+// may not filter by
+func (s Schema) authorizeWhere(p Parser, grp *PredGrp, role string, errs *ValidationErrors) *PredGrp {
+	return Rewrite(grp, func(path []string, pred *Pred) *Pred {
+		if pred.Column == "" {
+			return pred
+		}
+		c, ok := s.Column(pred.Column)
+		if !ok || !c.Can(role, PermFilter) {
+			*errs = append(*errs, ValidationError{Param: p.Col(path...), Message: "column not permitted: " + pred.Column})
+			return nil
+		}
+		if !c.Type.permits(pred.Operator) {
+			*errs = append(*errs, ValidationError{Param: p.Opr(path...), Message: "operator not permitted on " + pred.Column + ": " + pred.Operator})
+			return nil
+		}
+		return pred
+	})
+}
+
+// AddColumnKV adds template funcs listing the columns role may select, filter
+// and sort by, with their labels
+func (s Schema) AddColumnKV(funcs map[string]interface{}, role string) map[string]interface{} {
+	columnKV := func(perm Perm) func() []KV {
+		return func() []KV {
+			var kvs []KV
+			for _, c := range s.Permitted(role, perm) {
+				label := c.Label
+				if label == "" {
+					label = c.Name
+				}
+				kvs = append(kvs, KV{Key: c.Name, Value: label})
+			}
+			return kvs
+		}
+	}
+	funcs["GetqlSelectColKV"] = columnKV(PermSelect)
+	funcs["GetqlFilterColKV"] = columnKV(PermFilter)
+	funcs["GetqlSortColKV"] = columnKV(PermSort)
+	return funcs
+}
+
+// AddOperatorKV adds a template func listing the operators a column may be
+// filtered with, as tokens of p
+func (s Schema) AddOperatorKV(funcs map[string]interface{}, p Parser) map[string]interface{} {
+	funcs["GetqlColOprKV"] = func(name string) []KV {
+		c, _ := s.Column(name)
+		return p.operatorKV(c.Type)
+	}
+	return funcs
+}
+
+// SchemaOf derives the schema of table from the getql tags on the fields of
+// model, a struct or a pointer to one. Only tagged fields are columns, and
+// every column may be selected. A tag looks like
+//
+//	getql:"name,filter,sort,type=text,order=asc,label=Customer name"
+//
+// where the name defaults to the field's db column, filter and sort grant
+// PermFilter and PermSort, the type defaults to one that suits the field, and
+// order adds the column to the default order. The label takes up the rest of
+// the tag, commas included. Fields of nested structs aren't columns, but fields
+// of untagged embedded structs are.
+func SchemaOf(table string, model interface{}) (Schema, error) {
+	s := Schema{Table: table}
+	t := reflect.TypeOf(model)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return s, fmt.Errorf("getql: SchemaOf needs a struct, not %T", model)
+	}
+	fields, columns := structColumns(t)
+	for _, column := range columns {
+		field := fields[column].Field
+		tag, ok := field.Tag.Lookup("getql")
+		if !ok || tag == "-" {
+			continue
+		}
+		c := Column{Name: column, Type: fieldType(field.Type), Perm: PermSelect}
+		options := strings.Split(tag, ",")
+		if options[0] != "" {
+			c.Name = options[0]
+		}
+		for i := 1; i < len(options); i++ {
+			option := options[i]
+			key, value := option, ""
+			if j := strings.Index(option, "="); j >= 0 {
+				key, value = option[:j], option[j+1:]
+			}
+			switch key {
+			case "filter":
+				c.Perm |= PermFilter
+			case "sort":
+				c.Perm |= PermSort
+			case "type":
+				c.Type = ColumnType(value)
+				switch c.Type {
+				case TypeAny, TypeText, TypeNumber, TypeEnum, TypeDate:
+				default:
+					return s, fmt.Errorf("getql: %s.%s: unknown type %q", t.Name(), field.Name, value)
+				}
+			case "order":
+				order := strings.ToUpper(value)
+				if order != Asc && order != Desc {
+					return s, fmt.Errorf("getql: %s.%s: unknown order %q", t.Name(), field.Name, value)
+				}
+				s.OrderBys = append(s.OrderBys, OrderBy{Column: c.Name, Order: order})
+			case "label":
+				c.Label = strings.Join(append([]string{value}, options[i+1:]...), ",")
+				i = len(options)
+			default:
+				return s, fmt.Errorf("getql: %s.%s: unknown option %q", t.Name(), field.Name, option)
+			}
+		}
+		s.Columns = append(s.Columns, c)
+	}
+	return s, nil
+}
+
+// fieldType returns the column type that suits a field of type t
+func fieldType(t reflect.Type) ColumnType {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch {
+	case t == reflect.TypeOf(time.Time{}) || t == reflect.TypeOf(sql.NullTime{}):
+		return TypeDate
+	case t == reflect.TypeOf(sql.NullString{}):
+		return TypeText
+	case t == reflect.TypeOf(sql.NullInt64{}) || t == reflect.TypeOf(sql.NullInt32{}) || t == reflect.TypeOf(sql.NullFloat64{}):
+		return TypeNumber
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return TypeText
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return TypeNumber
+	}
+	return TypeAny
+}
@@ -0,0 +1,191 @@
+package getql
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSchemaAuthorize(t *testing.T) {
+	schema := Schema{
+		Table: "employees",
+		Columns: []Column{
+			{Name: "name", Perm: PermAll},
+			{Name: "salary", Perm: PermAll, Roles: map[string]Perm{"support": 0}},
+			{Name: "ssn", Perm: PermSelect},
+		},
+	}
+	params := map[string][]string{
+		Sel:      []string{"*"},
+		Frm:      []string{"employees"},
+		Col("1"): []string{"name"},
+		Opr("1"): []string{Eq},
+		Val("1"): []string{"bob"},
+		Col("2"): []string{"ssn"},
+		Opr("2"): []string{Eq},
+		Val("2"): []string{"123"},
+		Ord("1"): []string{"salary", Desc},
+	}
+	sq := ParseSelect(params)
+	if _, err := schema.Authorize(sq, "support"); err == nil {
+		t.Errorf("got nil error, want ValidationErrors")
+	}
+	schema.Policy = PolicyDrop
+	authorized, err := schema.Authorize(sq, "support")
+	if err != nil {
+		t.Fatal(err)
+	}
+	query, _ := authorized.Sql()
+	want := `SELECT "name", "ssn" FROM "employees" WHERE "name" = $1`
+	if query != want {
+		t.Errorf("got %q, want %q", query, want)
+	}
+	query, _ = sq.Sql(SelectAll, schema.Restrict("admin"))
+	want = `SELECT "name", "salary", "ssn" FROM "employees" WHERE "name" = $1 ORDER BY "salary" DESC`
+	if query != want {
+		t.Errorf("got %q, want %q", query, want)
+	}
+	if len(sq.Where.Preds) != 2 {
+		t.Errorf("Authorize modified the original query")
+	}
+	schema.Policy = PolicyReject
+	count := ParseSelect(map[string][]string{Sel: []string{Count}, Frm: []string{"employees"}})
+	if _, err := schema.Authorize(count, "admin"); err == nil {
+		t.Errorf("got nil error selecting %s, want ValidationErrors", Count)
+	}
+	query, _ = sq.Sql(SelectCount, schema.Restrict("admin"))
+	want = `SELECT COUNT(*) FROM "employees" WHERE "name" = $1`
+	if query != want {
+		t.Errorf("got %q, want %q", query, want)
+	}
+}
+
+func TestSchemaAuthorizeParams(t *testing.T) {
+	schema := Schema{
+		Table: "employees",
+		Columns: []Column{
+			{Name: "name", Type: TypeText, Perm: PermAll},
+			{Name: "salary", Type: TypeNumber, Perm: PermSelect},
+		},
+	}
+	p := DefaultParser.WithNamespace("ns")
+	p.Suffixes.Col = "FLD"
+	params := map[string][]string{
+		p.Key(p.Suffixes.Sel): []string{"ssn"},
+		p.Key(p.Suffixes.Frm): []string{"users"},
+		p.Col("1"):            []string{"name"},
+		p.Opr("1"):            []string{Gt},
+		p.Val("1"):            []string{"bob"},
+		p.Col("2", "1"):       []string{"salary"},
+		p.Opr("2", "1"):       []string{Eq},
+		p.Val("2", "1"):       []string{"10"},
+		p.Ord("2"):            []string{"salary", Desc},
+	}
+	sq, err := p.Parse(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = schema.AuthorizeWith(p, sq, "")
+	errs, ok := err.(ValidationErrors)
+	if !ok {
+		t.Fatalf("got %v, want ValidationErrors", err)
+	}
+	var got []string
+	for _, e := range errs {
+		got = append(got, e.Param)
+	}
+	want := []string{"ns:1.OPR", "ns:2.1.FLD", "ns:2.ORD", "ns:FRM", "ns:SEL"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestSchemaAuthorizeIgnore(t *testing.T) {
+	schema := Schema{
+		Table:   "employees",
+		Columns: []Column{{Name: "salary", Type: TypeNumber, Perm: PermAll}},
+	}
+	params := map[string][]string{
+		Sel:      []string{"salary"},
+		Col("1"): []string{"salary"},
+		Opr("1"): []string{Ignore},
+		Val("1"): []string{"10"},
+		Col("2"): []string{"salary"},
+		Val("2"): []string{"20"},
+	}
+	authorized, err := schema.Authorize(ParseSelect(params), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	query, _ := authorized.Sql()
+	want := `SELECT "salary" FROM "employees"`
+	if query != want {
+		t.Errorf("got %q, want %q", query, want)
+	}
+}
+
+func TestSchemaOf(t *testing.T) {
+	type customer struct {
+		ID      int       `db:"id" getql:",sort,order=desc"`
+		Name    string    `db:"name" getql:",filter,sort,label=Name, in full"`
+		Tier    string    `db:"tier" getql:",filter,type=enum"`
+		Created time.Time `db:"created_at" getql:"created,filter"`
+		Secret  string    `db:"secret"`
+	}
+	schema, err := SchemaOf("customers", &customer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Schema{
+		Table: "customers",
+		Columns: []Column{
+			{Name: "id", Type: TypeNumber, Perm: PermSelect | PermSort},
+			{Name: "name", Label: "Name, in full", Type: TypeText, Perm: PermAll},
+			{Name: "tier", Type: TypeEnum, Perm: PermSelect | PermFilter},
+			{Name: "created", Type: TypeDate, Perm: PermSelect | PermFilter},
+		},
+		OrderBys: []OrderBy{{Column: "id", Order: Desc}},
+	}
+	if !reflect.DeepEqual(schema, want) {
+		t.Errorf("got %+v, want %+v", schema, want)
+	}
+	type bad struct {
+		Name string `getql:",type=string"`
+	}
+	if _, err := SchemaOf("bad", bad{}); err == nil {
+		t.Error("got nil error for an unknown type")
+	}
+	// The types restrict the operators, and the default order applies
+	params := map[string][]string{
+		Col("1"): []string{"tier"},
+		Opr("1"): []string{Like},
+		Val("1"): []string{"gold%"},
+	}
+	if _, err := schema.Authorize(ParseSelect(params), ""); err == nil {
+		t.Error("got nil error for LIKE on an enum")
+	}
+	params[Opr("1")] = []string{In}
+	sq, err := schema.Authorize(ParseSelect(params), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	query, _ := sq.Sql()
+	wantQuery := `SELECT "id", "name", "tier", "created" FROM "customers" WHERE "tier" IN ($1) ORDER BY "id" DESC`
+	if query != wantQuery {
+		t.Errorf("got %q, want %q", query, wantQuery)
+	}
+}
+
+func TestColumnOprKV(t *testing.T) {
+	schema := Schema{Columns: []Column{{Name: "tier", Type: TypeEnum}}}
+	funcs := schema.AddOperatorKV(map[string]interface{}{}, DefaultParser)
+	kvs := funcs["GetqlColOprKV"].(func(string) []KV)("tier")
+	var keys []string
+	for _, kv := range kvs {
+		keys = append(keys, kv.Key)
+	}
+	if got := strings.Join(keys, " "); got != "EQ NE IN NULL NOTNULL IGNORE" {
+		t.Errorf("got %s", got)
+	}
+}
@@ -0,0 +1,156 @@
+package getql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/jmoiron/sqlx/reflectx"
+)
+
+// mapper maps db tags to struct fields the same way sqlx does by default
+var mapper = reflectx.NewMapperFunc("db", sqlx.NameMapper)
+
+// DBSelect is like DBSelectWithStatsContext, but scans the page of results
+// into a slice of T, a struct with db tags like the ones sqlx uses. The
+// selected columns must all be on T, and if no columns are selected T's
+// columns are, see structColumns. Pages of results aren't cached.
+func DBSelect[T any](ctx context.Context, db Queryer, params map[string][]string, options ...SelectStatsOption) (results []T, stats SelectStats, err error) {
+	config := newSelectStatsConfig(options)
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if t.Kind() != reflect.Struct {
+		return results, stats, fmt.Errorf("getql: DBSelect needs a struct, not %s", t)
+	}
+	fields, columns := structColumns(t)
+	sq, stats, err := config.prepare(params)
+	if err != nil {
+		return results, stats, err
+	}
+	selKey := config.Parser.Key(config.Parser.Suffixes.Sel)
+	if len(removeEmptyStrings(params[selKey])) == 0 {
+		sq.Select = nil
+		for _, column := range columns {
+			if config.Schema == nil || config.Schema.can(config.Role, column, PermSelect) {
+				sq.Select = append(sq.Select, column)
+			}
+		}
+	}
+	var errs ValidationErrors
+	for _, column := range sq.Select {
+		if fields[column] == nil {
+			errs = append(errs, ValidationError{Param: selKey, Message: "column not in " + t.String() + ": " + column})
+		}
+	}
+	if err = errs.err(); err != nil {
+		return results, stats, err
+	}
+	config.describe(sq, &stats)
+	queryOptions, window, err := config.beforePage(ctx, db, sq, &stats)
+	if err != nil {
+		return results, stats, err
+	}
+	results, total, err := scanStructs[T](ctx, db, config, sq, fields, queryOptions)
+	if err != nil {
+		return results, stats, err
+	}
+	keep, err := config.afterPage(ctx, db, sq, &stats, len(results), window, total)
+	return results[:keep], stats, err
+}
+
+// scanStructs runs sq and scans each row into a T. The total is read from
+// WindowCountColumn, if it is selected.
+func scanStructs[T any](ctx context.Context, db Queryer, config SelectStatsConfig, sq SelectQuery, fields map[string]*reflectx.FieldInfo, options []SelectOption) (results []T, total int, err error) {
+	query, args := sq.Sql(options...)
+	queryCtx, cancel := config.withTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	rows, err := db.QueryContext(queryCtx, query, args...)
+	if err != nil {
+		return results, total, err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return results, total, err
+	}
+	var windowTotal sql.NullString
+	for rows.Next() {
+		var result T
+		v := reflect.ValueOf(&result).Elem()
+		dest := make([]interface{}, len(columns))
+		for i, column := range columns {
+			if column == WindowCountColumn {
+				dest[i] = &windowTotal
+				continue
+			}
+			field := fields[column]
+			if field == nil {
+				return results, total, fmt.Errorf("getql: column %s not in %T", column, result)
+			}
+			dest[i] = reflectx.FieldByIndexes(v, field.Index).Addr().Interface()
+		}
+		err = rows.Scan(dest...)
+		if err != nil {
+			return results, total, err
+		}
+		results = append(results, result)
+	}
+	err = rows.Err()
+	if err != nil {
+		return results, total, err
+	}
+	if windowTotal.Valid {
+		total, err = strconv.Atoi(windowTotal.String)
+		if err != nil {
+			return results, total, fmt.Errorf("getql: reading %s: %v", WindowCountColumn, err)
+		}
+	}
+	return results, total, nil
+}
+
+// structColumns returns the columns of struct type t in the order they are
+// declared, and the fields they map to. Fields of untagged embedded structs
+// are included. The fields of nested structs, which sqlx maps to dotted paths
+// like inner.name rather than to columns, are left out, but a nested struct
+// that is an sql.Scanner is a column itself.
+func structColumns(t reflect.Type) (fields map[string]*reflectx.FieldInfo, columns []string) {
+	fields = make(map[string]*reflectx.FieldInfo)
+	scanner := reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	var walk func(parent *reflectx.FieldInfo)
+	walk = func(parent *reflectx.FieldInfo) {
+		for _, field := range parent.Children {
+			if field == nil {
+				continue
+			}
+			if !field.Embedded && (!hasChildren(field) || reflect.PtrTo(field.Field.Type).Implements(scanner)) {
+				if strings.Contains(field.Path, ".") {
+					// In a tagged embedded struct
+					continue
+				}
+				if fields[field.Path] == nil {
+					fields[field.Path] = field
+					columns = append(columns, field.Path)
+				}
+				continue
+			}
+			if !field.Embedded {
+				continue
+			}
+			walk(field)
+		}
+	}
+	walk(mapper.TypeMap(t).Tree)
+	return fields, columns
+}
+
+func hasChildren(field *reflectx.FieldInfo) bool {
+	for _, child := range field.Children {
+		if child != nil {
+			return true
+		}
+	}
+	return false
+}
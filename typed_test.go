@@ -0,0 +1,128 @@
+package getql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+)
+
+type testFruit struct {
+	ID    int            `db:"id"`
+	Name  string         `db:"name"`
+	Color sql.NullString `db:"color"`
+	Price int            `db:"price"`
+}
+
+func TestDBSelect(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+	ctx := context.Background()
+	params := map[string][]string{
+		Frm:      []string{"fruits"},
+		Col("1"): []string{"color"},
+		Opr("1"): []string{Eq},
+		Val("1"): []string{"red"},
+		Ord("1"): []string{"id", Asc},
+	}
+	// Without SEL every column on testFruit is selected
+	fruits, stats, err := DBSelect[testFruit](ctx, db, params, SelectStatsDialect(SQLite))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(fruits) != "[{3 fruit3 {red true} 30} {6 fruit6 {red true} 60} {9 fruit9 {red true} 90} {12 fruit12 {red true} 120}]" {
+		t.Errorf("got %v", fruits)
+	}
+	want := `SELECT "id", "name", "color", "price" FROM "fruits" WHERE "color" = 'red' ORDER BY "id" ASC LIMIT 5;`
+	if stats.Total != 4 || stats.Query != want {
+		t.Errorf("got stats %+v, want query %q", stats, want)
+	}
+	params[Sel] = []string{"id", "name"}
+	fruits, _, err = DBSelect[testFruit](ctx, db, params, SelectStatsDialect(SQLite), SelectStatsWindowCount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fruits) != 4 || fruits[0].Name != "fruit3" || fruits[0].Price != 0 {
+		t.Errorf("got %v", fruits)
+	}
+	params[Sel] = []string{"id", "secret"}
+	_, _, err = DBSelect[testFruit](ctx, db, params, SelectStatsDialect(SQLite))
+	if _, ok := err.(ValidationErrors); !ok {
+		t.Errorf("got err %v, want ValidationErrors", err)
+	}
+}
+
+func TestDBSelectCounts(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+	ctx := context.Background()
+	params := map[string][]string{
+		Sel:  []string{"id"},
+		Frm:  []string{"fruits"},
+		Page: []string{"2"},
+	}
+	tests := []struct {
+		option  SelectStatsOption
+		total   int
+		hasNext bool
+	}{
+		{SelectStatsCount(CountExact), 12, true},
+		{SelectStatsWindowCount, 12, true},
+		{SelectStatsNoCount, 0, true},
+	}
+	for _, tt := range tests {
+		fruits, stats, err := DBSelect[testFruit](ctx, db, params, SelectStatsDialect(SQLite), tt.option)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(fruits) != 5 || stats.Total != tt.total || stats.HasNext != tt.hasNext || !stats.HasPrev {
+			t.Errorf("got %d fruits, stats %+v", len(fruits), stats)
+		}
+	}
+}
+
+type testPrice struct {
+	Price int `db:"price"`
+}
+
+type testNamed struct {
+	Name string `db:"name"`
+}
+
+func TestDBSelectNested(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+	params := map[string][]string{
+		Frm:      []string{"fruits"},
+		Col("1"): []string{"id"},
+		Opr("1"): []string{Eq},
+		Val("1"): []string{"3"},
+	}
+	// The embedded struct's price is a column, the nested struct's name isn't
+	type fruit struct {
+		ID int `db:"id"`
+		testPrice
+		Inner testNamed `db:"inner"`
+	}
+	fruits, stats, err := DBSelect[fruit](context.Background(), db, params, SelectStatsDialect(SQLite))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `SELECT "id", "price" FROM "fruits" WHERE "id" = '3' LIMIT 5;`
+	if stats.Query != want {
+		t.Errorf("got query %q, want %q", stats.Query, want)
+	}
+	if len(fruits) != 1 || fruits[0].Price != 30 || fruits[0].Inner.Name != "" {
+		t.Errorf("got %+v", fruits)
+	}
+	schema, err := SchemaOf("fruits", struct {
+		ID    int       `db:"id" getql:""`
+		Inner testNamed `db:"inner" getql:""`
+	}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(schema.Columns) != 1 || schema.Columns[0].Name != "id" {
+		t.Errorf("got columns %+v", schema.Columns)
+	}
+}
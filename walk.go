@@ -0,0 +1,87 @@
+package getql
+
+// Visitor is called by Walk for every predicate in a tree. path holds the keys
+// leading to pred, e.g. [4 1] for the predicate read from 4.1.COL. If pred is a
+// nested group and the Visitor returns false, the predicates inside the group
+// are skipped.
+type Visitor func(path []string, pred *Pred) bool
+
+// Walk calls visit for every predicate in grp in the order they are rendered,
+// visiting a nested group before the predicates inside it
+func Walk(grp *PredGrp, visit Visitor) {
+	walk(grp, nil, visit)
+}
+
+func walk(grp *PredGrp, path []string, visit Visitor) {
+	if grp == nil {
+		return
+	}
+	for _, key := range sortedKeys(grp.Preds) {
+		pred := grp.Preds[key]
+		if pred == nil {
+			continue
+		}
+		predPath := append(path[:len(path):len(path)], key)
+		if visit(predPath, pred) && pred.Nested {
+			walk(pred.PredGrp, predPath, visit)
+		}
+	}
+}
+
+// Rewrite returns a copy of grp with every predicate that isn't a nested group
+// replaced by rewrite(path, pred). pred is a copy that rewrite may modify and
+// return. If rewrite returns nil the predicate is removed, and nested groups
+// left without any predicates are removed as well. grp itself is not modified.
+func Rewrite(grp *PredGrp, rewrite func(path []string, pred *Pred) *Pred) *PredGrp {
+	return rewriteGrp(grp, nil, rewrite)
+}
+
+func rewriteGrp(grp *PredGrp, path []string, rewrite func(path []string, pred *Pred) *Pred) *PredGrp {
+	if grp == nil {
+		return nil
+	}
+	rewritten := &PredGrp{Or: grp.Or, Preds: make(map[string]*Pred)}
+	// Go in the order the predicates are rendered, so that rewrite sees them
+	// in a predictable order
+	for _, key := range sortedKeys(grp.Preds) {
+		pred := grp.Preds[key]
+		if pred == nil {
+			continue
+		}
+		predPath := append(path[:len(path):len(path)], key)
+		if pred.Nested {
+			nested := rewriteGrp(pred.PredGrp, predPath, rewrite)
+			if nested == nil || len(nested.Preds) == 0 && len(pred.PredGrp.Preds) > 0 {
+				continue
+			}
+			rewritten.Preds[key] = &Pred{Nested: true, PredGrp: nested}
+			continue
+		}
+		if pred = rewrite(predPath, pred.Clone()); pred != nil {
+			rewritten.Preds[key] = pred
+		}
+	}
+	return rewritten
+}
+
+// FindPredsOnColumn returns the predicates in grp on column. The predicates
+// are part of grp, so modifying them modifies grp.
+func FindPredsOnColumn(grp *PredGrp, column string) (preds []*Pred) {
+	Walk(grp, func(path []string, pred *Pred) bool {
+		if !pred.Nested && pred.Column == column {
+			preds = append(preds, pred)
+		}
+		return true
+	})
+	return preds
+}
+
+// RemovePreds returns a copy of grp without the predicates that match
+func RemovePreds(grp *PredGrp, match func(pred *Pred) bool) *PredGrp {
+	return Rewrite(grp, func(path []string, pred *Pred) *Pred {
+		if match(pred) {
+			return nil
+		}
+		return pred
+	})
+}
@@ -0,0 +1,47 @@
+package getql
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWalkRewrite(t *testing.T) {
+	params := map[string][]string{
+		Frm:           []string{"t"},
+		Col("1"):      []string{"old_name"},
+		Opr("1"):      []string{Eq},
+		Val("1"):      []string{"x"},
+		Col("2", "1"): []string{"hidden"},
+		Opr("2", "1"): []string{Eq},
+		Val("2", "1"): []string{"y"},
+		Col("2", "2"): []string{"old_name"},
+		Opr("2", "2"): []string{Eq},
+		Val("2", "2"): []string{"z"},
+	}
+	sq := ParseSelect(params)
+	var paths []string
+	Walk(sq.Where, func(path []string, pred *Pred) bool {
+		paths = append(paths, strings.Join(path, "."))
+		return true
+	})
+	if got, want := strings.Join(paths, " "), "1 2 2.1 2.2"; got != want {
+		t.Errorf("got paths %q, want %q", got, want)
+	}
+	if preds := FindPredsOnColumn(sq.Where, "old_name"); len(preds) != 2 {
+		t.Errorf("got %d preds on old_name, want 2", len(preds))
+	}
+	where := Rewrite(sq.Where, func(path []string, pred *Pred) *Pred {
+		if pred.Column == "old_name" {
+			pred.Column = "new_name"
+		}
+		return pred
+	})
+	where = RemovePreds(where, func(pred *Pred) bool { return pred.Column == "hidden" })
+	query, _ := SelectQuery{From: "t", Where: where}.Sql()
+	if want := `FROM "t" WHERE "new_name" = $1 AND ("new_name" = $2)`; query != want {
+		t.Errorf("got %q, want %q", query, want)
+	}
+	if sq.Where.Preds["1"].Column != "old_name" {
+		t.Errorf("Rewrite modified the original tree")
+	}
+}